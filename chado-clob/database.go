@@ -4,7 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 
-	go_ora "github.com/sijms/go-ora/v2"
+	"github.com/migration-data-export/dbprofile"
+	_ "github.com/sijms/go-ora/v2"
 )
 
 const tableListQuery = `SELECT table_name 
@@ -39,20 +40,36 @@ ORDER BY
     c.table_name, 
     c.column_name`
 
-func (orc *OracleApp) setupDatabaseConnection() (*sql.DB, error) {
-	connStr := go_ora.BuildUrl(
-		orc.cltx.String("host"),
-		orc.cltx.Int("port"),
-		orc.cltx.String("service"),
-		orc.cltx.String("user"),
-		orc.cltx.String("password"),
-		nil,
+// connectionProfile builds the connection from the dsn or the profile
+// name, the host, port, service, user and password flags take precedence
+func (orc *OracleApp) connectionProfile() (*dbprofile.Profile, error) {
+	cltx := orc.cltx
+	prof := dbprofile.Profile{
+		Host:     cltx.String("host"),
+		Service:  cltx.String("service"),
+		User:     cltx.String("user"),
+		Password: cltx.String("password"),
+	}
+	if cltx.IsSet("port") {
+		prof.Port = cltx.Int("port")
+	}
+	return dbprofile.FromValues(
+		prof,
+		cltx.String("dsn"),
+		cltx.String("profile-file"),
 	)
+}
 
-	dbh, err := sql.Open("oracle", connStr)
+func (orc *OracleApp) setupDatabaseConnection() (*sql.DB, error) {
+	prof, err := orc.connectionProfile()
+	if err != nil {
+		return nil, err
+	}
+	dbh, err := sql.Open("oracle", prof.URL())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
+	orc.prof = prof
 	return dbh, nil
 }
 
@@ -4,6 +4,7 @@ go 1.23.5
 
 require (
 	github.com/jmoiron/sqlx v1.3.5
+	github.com/migration-data-export/dbprofile v0.0.0-00010101000000-000000000000
 	github.com/sijms/go-ora/v2 v2.8.23
 	github.com/stretchr/testify v1.10.0
 	github.com/urfave/cli/v2 v2.27.1
@@ -15,5 +16,8 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
+	gopkg.in/yaml.v1 v1.0.0-20140924161607-9f9df34309c0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/migration-data-export/dbprofile => ../dbprofile
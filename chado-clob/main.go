@@ -1,17 +1,17 @@
 package main
 
 import (
-	"database/sql"
 	"fmt"
 	"log"
 	"os"
 
-	go_ora "github.com/sijms/go-ora/v2"
+	"github.com/migration-data-export/dbprofile"
 	"github.com/urfave/cli/v2"
 )
 
 type OracleApp struct {
 	cltx *cli.Context
+	prof *dbprofile.Profile
 }
 
 func main() {
@@ -46,10 +46,19 @@ func main() {
 		},
 		Flags: []cli.Flag{
 			&cli.StringFlag{
-				Name:     "host",
-				Aliases:  []string{"H"},
-				Usage:    "Database host",
-				Required: true,
+				Name:    "dsn",
+				Usage:   "Database connection as DBI dsn, EZConnect, go-ora url or profile name",
+				EnvVars: []string{"ORACLE_DSN"},
+			},
+			&cli.StringFlag{
+				Name:    "profile-file",
+				Usage:   "Yaml file of named connection profiles",
+				EnvVars: []string{"DB_PROFILE_FILE"},
+			},
+			&cli.StringFlag{
+				Name:    "host",
+				Aliases: []string{"H"},
+				Usage:   "Database host",
 			},
 			&cli.IntFlag{
 				Name:    "port",
@@ -58,22 +67,19 @@ func main() {
 				Value:   1521,
 			},
 			&cli.StringFlag{
-				Name:     "service",
-				Aliases:  []string{"s"},
-				Usage:    "Database service name",
-				Required: true,
+				Name:    "service",
+				Aliases: []string{"s"},
+				Usage:   "Database service name",
 			},
 			&cli.StringFlag{
-				Name:     "user",
-				Aliases:  []string{"u"},
-				Usage:    "Database user",
-				Required: true,
+				Name:    "user",
+				Aliases: []string{"u"},
+				Usage:   "Database user",
 			},
 			&cli.StringFlag{
-				Name:     "password",
-				Aliases:  []string{"p"},
-				Usage:    "Database password",
-				Required: true,
+				Name:    "password",
+				Aliases: []string{"p"},
+				Usage:   "Database password",
 			},
 			&cli.StringFlag{
 				Name:    "output-folder",
@@ -107,7 +113,7 @@ func (orc *OracleApp) clobStatsAction() error {
 	}
 	defer dbh.Close()
 
-	rows, err := orc.queryClobTables(dbh, orc.cltx.String("user"))
+	rows, err := orc.queryClobTables(dbh, orc.prof.User)
 	if err != nil {
 		return cli.Exit(fmt.Sprintf("Query failed: %v", err), 1)
 	}
@@ -145,7 +151,7 @@ func (orc *OracleApp) listTablesAction() error {
 	}
 	defer dbh.Close()
 
-	rows, err := dbh.Query(tableListQuery, orc.cltx.String("user"))
+	rows, err := dbh.Query(tableListQuery, orc.prof.User)
 	if err != nil {
 		return cli.Exit(fmt.Sprintf("Query failed: %v", err), 1)
 	}
@@ -180,19 +186,7 @@ func (orc *OracleApp) listTablesAction() error {
 }
 
 func (orc *OracleApp) pingAction() error {
-	cltx := orc.cltx
-	// Build connection string
-	connStr := go_ora.BuildUrl(
-		cltx.String("host"),
-		cltx.Int("port"),
-		cltx.String("service"),
-		cltx.String("user"),
-		cltx.String("password"),
-		nil,
-	)
-
-	// Open database connection
-	dbh, err := sql.Open("oracle", connStr)
+	dbh, err := orc.setupDatabaseConnection()
 	if err != nil {
 		return cli.Exit(fmt.Sprintf("Failed to connect: %v", err), 1)
 	}
@@ -1,10 +1,10 @@
 package main
 
 import (
-	"fmt"
-	"os/exec"
+	"context"
 	"path/filepath"
-	"strings"
+
+	"github.com/migration-data-export/runner"
 	"github.com/urfave/cli"
 )
 
@@ -19,40 +19,31 @@ func ColleaguesAction(c *cli.Context) error {
 	if err := CreateFolder(c.String("output-folder")); err != nil {
 		return cli.NewExitError(err.Error(), 2)
 	}
-	if err := exportColleagues(c); err != nil {
-		return cli.NewExitError(err.Error(), 2)
-	}
-	return nil
-}
-
-func exportColleagues(c *cli.Context) error {
-	log := getLogger(c)
-	mainCmd, err := exec.LookPath("modware-export")
-	if err != nil {
-		return fmt.Errorf("could not find binary %s", err)
-	}
-	subCmd := makeColleaguesExportCmd(c)
-	log.Infof("running the command %s", strings.Join(subCmd, " "))
-	b, err := exec.Command(mainCmd, subCmd...).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("error %s running the command %s with output %s", err, strings.Join(subCmd, " "), string(b))
-	}
-	log.Info("successfully ran the command")
-	return nil
+	opt := makeColleaguesExportOptions(c)
+	return runJobs(c, []runner.Job{
+		{
+			Name:       "colleague",
+			Subcommand: "colleague",
+			Outputs: []string{
+				filepath.Join(c.String("output-folder"), "user_relations.csv"),
+				filepath.Join(c.String("output-folder"), "users.csv"),
+			},
+			Command: append([]string{"modware-export"}, opt.Args("colleague")...),
+			Run: func(ctx context.Context) error {
+				return RunLegacyExportCmd(
+					ctx, NewJobLogger(c, "colleague"),
+					opt, "colleague", c.String("legacy-password"),
+				)
+			},
+		},
+	})
 }
 
-func makeColleaguesExportCmd(c *cli.Context) []string {
-	return []string{
-		"colleague",
-		"--dsn",
-		c.String("legacy-dsn"),
-		"-u",
-		c.String("legacy-user"),
-		"-p",
-		c.String("legacy-password"),
-		"--crel",
-		filepath.Join(c.String("output-folder"), "user_relations.csv"),
-		"--cout",
-		filepath.Join(c.String("output-folder"), "users.csv"),
+func makeColleaguesExportOptions(c *cli.Context) Options {
+	return Options{
+		{"dsn", c.String("legacy-dsn")},
+		{"u", c.String("legacy-user")},
+		{"crel", filepath.Join(c.String("output-folder"), "user_relations.csv")},
+		{"cout", filepath.Join(c.String("output-folder"), "users.csv")},
 	}
 }
@@ -1,164 +1,55 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
-	"sync"
+	"text/tabwriter"
 	"time"
+
+	"github.com/migration-data-export/runner"
 	"github.com/urfave/cli"
 )
 
-var ur = regexp.MustCompile("-")
-var dbRgxp = regexp.MustCompile(`Dbxref=(.+)(;)?`)
-
 func CanonicalGFF3Action(c *cli.Context) error {
+	return manifestAction(c)
+}
+
+func ExtraGFF3Action(c *cli.Context) error {
+	return manifestAction(c)
+}
+
+// manifestAction runs the jobs of the manifest of the command, the manifest
+// given in the command line or else the default one named after the command
+func manifestAction(c *cli.Context) error {
 	if !ValidateArgs(c) {
 		return cli.NewExitError("one or more of required arguments are not provided", 2)
 	}
-	if !ValidateMultiArgs(c) {
-		return cli.NewExitError("one or more of required arguments are not provided", 2)
-	}
-	// create config folder if not exists
-	CreateRequiredFolder(c.String("config-folder"))
-
-	errChan := make(chan error)
-	out := make(chan []byte)
-	mopt := map[string]map[string]string{
-		"purpureum": {
-			"genus":   "Dictyostelium",
-			"species": "purpureum",
-		},
-		"pallidum": {
-			"genus":   "Polysphondylium",
-			"species": "pallidum PN500",
-		},
-		"pallidum_mitochondrial": {
-			"genus":   "Polysphondylium",
-			"species": "pallidum CK8",
-		},
-		"fasciculatum": {
-			"genus":                 "Dictyostelium",
-			"species":               "fasciculatum SH3",
-			"exclude_mitochondrial": "1",
-		},
-		"fasciculatum_mitochondrial": {
-			"genus":              "Dictyostelium",
-			"species":            "fasciculatum SH3",
-			"only_mitochondrial": "1",
-		},
+	m, err := LoadCommandManifest(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
 	}
-	var subf string
-	var name string
-	for k, v := range mopt {
-		if strings.Contains(k, "_") {
-			sn := strings.Split(k, "_")
-			subf = sn[0]
-			name = fmt.Sprintf("%s_canonical_%s", sn[0], sn[1])
-		} else {
-			subf = k
-			name = fmt.Sprintf("%s_canonical_core", k)
-		}
-		conf := MakeCustomConfigFile(c, name, subf)
-		CreateFolderFromYaml(conf)
-		opt := make(map[string]string)
-		for ik, iv := range v {
-			opt[ik] = iv
-		}
-		opt["config"] = conf
-		go RunExportCmd(opt, "chado2canonicalgff3", errChan, out)
-	}
-	dc := MakeDictyConfigFile(c, "canonical_core", "discoideum")
-	CreateFolderFromYaml(dc)
-	dopt := map[string]string{"config": dc}
-	go RunExportCmd(dopt, "chado2dictycanonicalgff3", errChan, out)
-
-	count := 1
-	curr := time.Now()
-	for {
-		select {
-		case r := <-out:
-			log.Printf("\nfinished the %s\n succesfully", string(r))
-			count++
-			if count > 6 {
-				return nil
-			}
-		case err := <-errChan:
-			log.Printf("\nError %s in running command\n", err)
-			count++
-			if count > 6 {
-				return nil
-			}
-		default:
-			time.Sleep(1000 * time.Millisecond)
-			elapsed := time.Since(curr)
-			fmt.Printf("\r%d:%d:%d\t", int(elapsed.Hours()), int(elapsed.Minutes()), int(elapsed.Seconds()))
-		}
+	if err := validateManifest(m, c.Command.Name); err != nil {
+		return cli.NewExitError(err.Error(), 2)
 	}
-	return nil
-}
-
-func ExtraGFF3Action(c *cli.Context) error {
-	if !ValidateArgs(c) {
+	if m.UsesTemplate("custom") && !ValidateMultiArgs(c) {
 		return cli.NewExitError("one or more of required arguments are not provided", 2)
 	}
 	// create config folder if not exists
 	CreateRequiredFolder(c.String("config-folder"))
 
-	errChan := make(chan error)
-	out := make(chan []byte)
-	cmap := map[string]string{
-		"chado2dictynoncodinggff3":      "canonical_noncoding",
-		"chado2dictynoncanonicalgff3":   "noncanonical_seq_center",
-		"chado2dictynoncanonicalv2gff3": "noncanonical_norepred",
-		"chado2dictycuratedgff3":        "noncanonical_curated",
-	}
-	for k, v := range cmap {
-		conf := map[string]string{"config": MakeDictyConfigFile(c, v, "discoideum")}
-		CreateFolderFromYaml(conf["config"])
-		go RunExportCmd(conf, k, errChan, out)
-	}
-	ao := []map[string]string{
-		map[string]string{"organism": "dicty", "reference_type": "chromosome", "feature_type": "EST"},
-		map[string]string{"organism": "dicty", "reference_type": "chromosome", "feature_type": "cDNA_clone"},
-		map[string]string{"organism": "dicty", "reference_type": "chromosome", "feature_type": "databank_entry", "match_type": "nucleotide_match"},
-	}
-	for _, m := range ao {
-		m["config"] = MakeDictyConfigFile(c, m["feature_type"], "discoideum")
-		go RunExportCmd(m, "chado2alignmentgff3", errChan, out)
-	}
-
-	count := 1
-	//curr := time.Now()
-	for {
-		select {
-		case r := <-out:
-			log.Printf("\nfinished the %s\n succesfully", string(r))
-			count++
-			if count > 7 {
-				return nil
-			}
-		case err := <-errChan:
-			log.Printf("\nError %s in running command\n", err)
-			count++
-			if count > 7 {
-				return nil
-			}
-		default:
-			time.Sleep(100 * time.Millisecond)
-			//elapsed := time.Since(curr)
-			//fmt.Printf("\r%d:%d:%d\t", int(elapsed.Hours()), int(elapsed.Minutes()), int(elapsed.Seconds()))
-		}
+	var jobs []runner.Job
+	for _, j := range m.Jobs {
+		jobs = append(jobs, exportJob(c, j.Name, MakeJobOptions(c, j), j.Command))
 	}
-	return nil
+	if c.Bool("validate") {
+		return runJobStages(c, jobs, validationJobs(jobs))
+	}
+	return runJobs(c, jobs)
 }
 
 func LiteratureAction(c *cli.Context) error {
@@ -173,38 +64,61 @@ func LiteratureAction(c *cli.Context) error {
 	} {
 		CreateRequiredFolder(f)
 	}
-	pconf := map[string]string{
-		"config": MakeLiteatureConfig(c, "chadopub2bib"),
-		"email":  c.String("email"),
-		"output": filepath.Join(c.String("output-folder"), "dictytemp.bib"),
-	}
-	dconf := map[string]string{
-		"conf":   MakeLiteatureConfig(c, "dictybib"),
-		"output": filepath.Join(c.String("output-folder"), "dictybib.bib"),
-		"input":  filepath.Join(c.String("output-folder"), "dictytemp.bib"),
-	}
-	gconf := map[string]string{
-		"config": MakePub2BibConfig(c, "dictygenomespub"),
-		"input":  filepath.Join(c.String("output-folder"), "dictygenomes_pubid.txt"),
-	}
-	nconf := map[string]string{
-		"conf":   MakeLiteatureConfig(c, "dictynonpub"),
-		"output": filepath.Join(c.String("output-folder"), "dictynonpub.bib"),
-	}
-	aconf := map[string]string{
-		"conf":   MakeLiteatureConfig(c, "dictypubannotation"),
-		"output": filepath.Join(c.String("output-folder"), "dictypubannotation.csv"),
-	}
-
-	wg := new(sync.WaitGroup)
-	wg.Add(4)
-	go RunLiteratureExportCmd(pconf, "chadopub2bib", wg)
-	go RunTransformCmd(gconf, "pub2bib", gconf["input"], wg)
-	go RunLiteratureExportCmd(nconf, "dictynonpub2bib", wg)
-	go RunLiteratureExportCmd(aconf, "dictypubannotation", wg)
-	wg.Wait()
-	RunLiteratureUpdateCmd(dconf, "dictybib")
-	return nil
+	pconf := Options{
+		{"config", MakeLiteatureConfig(c, "chadopub2bib")},
+		{"email", c.String("email")},
+		{"output", filepath.Join(c.String("output-folder"), "dictytemp.bib")},
+	}
+	dconf := Options{
+		{"conf", MakeLiteatureConfig(c, "dictybib")},
+		{"output", filepath.Join(c.String("output-folder"), "dictybib.bib")},
+		{"input", filepath.Join(c.String("output-folder"), "dictytemp.bib")},
+	}
+	gconf := Options{
+		{"config", MakePub2BibConfig(c, "dictygenomespub")},
+		{"input", filepath.Join(c.String("output-folder"), "dictygenomes_pubid.txt")},
+	}
+	nconf := Options{
+		{"conf", MakeLiteatureConfig(c, "dictynonpub")},
+		{"output", filepath.Join(c.String("output-folder"), "dictynonpub.bib")},
+	}
+	aconf := Options{
+		{"conf", MakeLiteatureConfig(c, "dictypubannotation")},
+		{"output", filepath.Join(c.String("output-folder"), "dictypubannotation.csv")},
+	}
+
+	pub2bib := runner.Job{
+		Name:       "dictygenomespub",
+		Subcommand: "pub2bib",
+		Outputs:    optionOutputs(gconf),
+		Configs:    optionConfigs(gconf),
+		Command:    append([]string{"modware-transform"}, gconf.Args("pub2bib")...),
+		Run: func(ctx context.Context) error {
+			input, _ := gconf.Get("input")
+			return RunTransformCmd(ctx, NewJobLogger(c, "dictygenomespub"), gconf, "pub2bib", input)
+		},
+	}
+	dictybib := runner.Job{
+		Name:       "dictybib",
+		Subcommand: "dictybib",
+		Outputs:    optionOutputs(dconf),
+		Configs:    optionConfigs(dconf),
+		Command:    append([]string{"modware-update"}, dconf.Args("dictybib")...),
+		Run: func(ctx context.Context) error {
+			return RunLiteratureUpdateCmd(ctx, NewJobLogger(c, "dictybib"), dconf, "dictybib")
+		},
+	}
+	// the update needs the output of chadopub2bib
+	return runJobStages(
+		c,
+		[]runner.Job{
+			literatureJob(c, "chadopub2bib", pconf, "chadopub2bib"),
+			pub2bib,
+			literatureJob(c, "dictynonpub2bib", nconf, "dictynonpub2bib"),
+			literatureJob(c, "dictypubannotation", aconf, "dictypubannotation"),
+		},
+		[]runner.Job{dictybib},
+	)
 }
 
 func GeneAnnoAction(c *cli.Context) error {
@@ -214,375 +128,299 @@ func GeneAnnoAction(c *cli.Context) error {
 	// create config folder if not exists
 	CreateRequiredFolder(c.String("config-folder"))
 
-	errChan := make(chan error)
-	out := make(chan []byte)
-	conf := make(map[string]string)
-	conf["config"] = MakeGeneralConfigFile(c, "genesummary", "csv")
-	CreateFolderFromYaml(conf["config"])
-	for _, param := range []string{"legacy-user", "legacy-password", "legacy-dsn"} {
-		opt := ur.ReplaceAllString(param, "_")
-		conf[opt] = c.String(param)
-	}
-	go RunExportCmd(conf, "chado2genesummary", errChan, out)
+	var jobs []runner.Job
+	yc := MakeGeneSummaryConfig(c, "genesummary")
+	CreateFolderFromYaml(yc)
+	jobs = append(jobs, exportJob(c, "genesummary", Options{{"config", yc}}, "chado2genesummary"))
 
 	for _, param := range []string{"public", "private"} {
-		conf := make(map[string]string)
-		conf["config"] = MakeGeneralConfigFile(c, param, "csv")
-		conf["note"] = param
-		go RunExportCmd(conf, "curatornotes", errChan, out)
-	}
-
-	conf2 := make(map[string]string)
-	conf2["conf"] = MakeGeneralConfigFile(c, "coll2gene", "csv")
-	go RunExportCmd(conf2, "colleague2gene", errChan, out)
-
-	count := 1
-	//curr := time.Now()
-	for {
-		select {
-		case r := <-out:
-			fmt.Printf("\nfinished the %s\n succesfully", string(r))
-			count++
-			if count > 4 {
-				return nil
-			}
-		case err := <-errChan:
-			fmt.Printf("\nError %s in running command\n", err)
-			count++
-			if count > 4 {
-				return nil
-			}
-		default:
-			time.Sleep(100 * time.Millisecond)
-			//elapsed := time.Since(curr)
-			//fmt.Printf("\r%d:%d:%d\t", int(elapsed.Hours()), int(elapsed.Minutes()), int(elapsed.Seconds()))
+		conf := Options{
+			{"config", MakeGeneralConfigFile(c, param, "csv")},
+			{"note", param},
 		}
+		jobs = append(jobs, exportJob(c, param, conf, "curatornotes"))
 	}
-	return nil
+
+	conf2 := Options{{"conf", MakeGeneralConfigFile(c, "coll2gene", "csv")}}
+	jobs = append(jobs, exportJob(c, "coll2gene", conf2, "colleague2gene"))
+	return runJobs(c, jobs)
 }
 
-func RunExportCmd(opt map[string]string, subcmd string, errChan chan<- error, out chan<- []byte) {
-	p := make([]string, 0)
-	p = append(p, subcmd)
-	for k, v := range opt {
-		p = append(p, fmt.Sprint("--", k), v)
+// runJobs runs the jobs within the parallel limit and timeout of the
+// command, prints a summary, writes the run manifest and returns an exit
+// error if any one of them did not complete
+func runJobs(c *cli.Context, jobs []runner.Job) error {
+	return runJobStages(c, jobs)
+}
+
+// runJobStages runs the stages one after another like runJobs, a stage
+// only starts after all the jobs of the previous one have completed
+func runJobStages(c *cli.Context, stages ...[]runner.Job) error {
+	// the configs are already written while building the jobs, a dry run
+	// has to remove them as well
+	var configs []string
+	for _, stage := range stages {
+		for _, j := range stage {
+			configs = append(configs, j.Configs...)
+		}
 	}
-	cmdline := strings.Join(p, " ")
-	log.Printf("going to run %s\n", cmdline)
-	b, err := exec.Command("modware-export", p...).CombinedOutput()
+	defer RemoveConfigs(c, configs)
+	if c.GlobalBool("dry-run") {
+		err := NewPlan(c, stages...).Write(os.Stdout, c.GlobalString("plan-format"))
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+		return nil
+	}
+	log := getLogger(c)
+	CreateRequiredFolder(stateFolder(c))
+	state, err := OpenStateStore(stateFolder(c), c.Command.Name)
 	if err != nil {
-		errChan <- fmt.Errorf("Status %s message %s for cmdline %s\n", err.Error(), string(b), cmdline)
-		return
+		return cli.NewExitError(err.Error(), 2)
+	}
+	r := runner.New(c.Int("parallel"))
+	r.Timeout = c.Duration("job-timeout")
+	if c.Bool("resume") {
+		r.Skip = state.Resumable
+	}
+	r.Done = func(j runner.Job, res runner.Result) {
+		switch res.Status {
+		case runner.Completed:
+			log.Infof("job %s finished successfully in %s", res.Name, res.Elapsed())
+		case runner.Skipped:
+			log.Infof("job %s is skipped as its output is unchanged", res.Name)
+		case runner.Cancelled:
+			log.Warnf("job %s is skipped %s", res.Name, res.Err)
+		default:
+			log.Errorf("job %s %s after %s %s", res.Name, res.Status, res.Elapsed(), res.Err)
+		}
+		if err := state.Record(j, res); err != nil {
+			log.Errorf("unable to record the state of job %s %s", res.Name, err)
+		}
+	}
+	start := time.Now()
+	var jobs []runner.Job
+	var results runner.Results
+	for _, stage := range stages {
+		jobs = append(jobs, stage...)
+		if results.Err() != nil {
+			for _, j := range stage {
+				now := time.Now()
+				results = append(results, runner.Result{
+					Name:   j.Name,
+					Status: runner.Cancelled,
+					Err:    fmt.Errorf("previous jobs did not complete"),
+					Start:  now,
+					End:    now,
+				})
+			}
+			continue
+		}
+		results = append(results, r.Run(CommandContext(c), stage)...)
 	}
-	out <- []byte(cmdline)
+	printSummary(results)
+	if err := WriteRunManifest(c, start, jobs, results); err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	if err := results.Err(); err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	return nil
 }
 
-func RunLiteratureExportCmd(opt map[string]string, subcmd string, wg *sync.WaitGroup) {
-	defer wg.Done()
-	p := make([]string, 0)
-	p = append(p, subcmd)
-	for k, v := range opt {
-		p = append(p, fmt.Sprint("--", k), v)
-	}
-	cmdline := strings.Join(p, " ")
-	log.Printf("going to run %s\n", cmdline)
-	b, err := exec.Command("modware-export", p...).CombinedOutput()
-	if err != nil {
-		fmt.Printf("Status %s message %s\n", err.Error(), string(b))
-	} else {
-		log.Printf("finished running %s\n", cmdline)
+func printSummary(results runner.Results) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "job\tstatus\telapsed")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, r.Status, r.Elapsed().Round(time.Second))
 	}
+	w.Flush()
 }
 
-func RunLiteratureUpdateCmd(opt map[string]string, subcmd string) {
-	p := make([]string, 0)
-	p = append(p, subcmd)
-	for k, v := range opt {
-		p = append(p, fmt.Sprint("--", k), v)
+func exportJob(c *cli.Context, name string, opt Options, subcmd string) runner.Job {
+	return runner.Job{
+		Name:       name,
+		Subcommand: subcmd,
+		Outputs:    optionOutputs(opt),
+		Configs:    optionConfigs(opt),
+		Command:    append([]string{"modware-export"}, opt.Args(subcmd)...),
+		Run: func(ctx context.Context) error {
+			return RunExportCmd(ctx, NewJobLogger(c, name), opt, subcmd)
+		},
 	}
-	cmdline := strings.Join(p, " ")
-	log.Printf("going to run %s\n", cmdline)
-	b, err := exec.Command("modware-update", p...).CombinedOutput()
-	if err != nil {
-		fmt.Printf("Status %s message %s\n", err.Error(), string(b))
-	} else {
-		log.Printf("finished running %s\n", cmdline)
+}
+
+func literatureJob(c *cli.Context, name string, opt Options, subcmd string) runner.Job {
+	return runner.Job{
+		Name:       name,
+		Subcommand: subcmd,
+		Outputs:    optionOutputs(opt),
+		Configs:    optionConfigs(opt),
+		Command:    append([]string{"modware-export"}, opt.Args(subcmd)...),
+		Run: func(ctx context.Context) error {
+			return RunLiteratureExportCmd(ctx, NewJobLogger(c, name), opt, subcmd)
+		},
 	}
+}
 
+func dumpJob(c *cli.Context, name string, opt Options, subcmd string) runner.Job {
+	return runner.Job{
+		Name:       name,
+		Subcommand: subcmd,
+		Outputs:    optionOutputs(opt),
+		Configs:    optionConfigs(opt),
+		Command:    append([]string{"modware-dump"}, opt.Args(subcmd)...),
+		Run: func(ctx context.Context) error {
+			return RunDumpCmd(ctx, NewJobLogger(c, name), opt, subcmd)
+		},
+	}
 }
 
-func RunTransformCmd(opt map[string]string, subcmd string, file string, wg *sync.WaitGroup) {
-	defer wg.Done()
-	// Write list of pubmed ids to the input file
-	err := ioutil.WriteFile(file, []byte("13319664\n15867862\n17246401\n"), 0644)
-	if err != nil {
-		fmt.Printf("Error creating input file %s\n", file)
-		return
+// optionConfigs returns the config files given in the options of a job
+func optionConfigs(opt Options) []string {
+	var configs []string
+	for _, k := range []string{"config", "conf"} {
+		if v, ok := opt.Get(k); ok {
+			configs = append(configs, v)
+		}
 	}
-	p := make([]string, 0)
-	p = append(p, subcmd)
-	for k, v := range opt {
-		p = append(p, fmt.Sprint("--", k), v)
+	return configs
+}
+
+// optionOutputs returns the outputs of a job that are given in its
+// options or in its config file
+func optionOutputs(opt Options) []string {
+	var outputs []string
+	for _, k := range []string{"output", "dir"} {
+		if v, ok := opt.Get(k); ok {
+			outputs = append(outputs, v)
+		}
 	}
-	cmdline := strings.Join(p, " ")
-	log.Printf("going to run %s\n", cmdline)
-	b, err := exec.Command("modware-transform", p...).CombinedOutput()
-	if err != nil {
-		fmt.Printf("Status %s message %s\n", err.Error(), string(b))
-	} else {
-		log.Printf("finished running %s\n", cmdline)
+	for _, conf := range optionConfigs(opt) {
+		outputs = append(outputs, ConfigOutputs(conf)...)
 	}
+	return outputs
+}
 
+func RunExportCmd(ctx context.Context, jl *JobLogger, opt Options, subcmd string) error {
+	return runModwareCmd(ctx, jl, "modware-export", opt.Args(subcmd))
 }
 
-func RunDumpCmd(opt map[string]string, subcmd string, errChan chan<- error, out chan<- []byte) {
-	p := make([]string, 0)
-	p = append(p, subcmd)
-	for k, v := range opt {
-		p = append(p, fmt.Sprint("--", k), v)
-	}
-	cmdline := strings.Join(p, " ")
-	log.Printf("going to run %s\n", cmdline)
-	b, err := exec.Command("modware-dump", p...).CombinedOutput()
+// RunLegacyExportCmd runs a modware-export subcommand that connects to the
+// legacy database with the --dsn and -u options, the password is given in
+// the DBI_PASS environment variable that DBI falls back to
+func RunLegacyExportCmd(ctx context.Context, jl *JobLogger, opt Options, subcmd, password string) error {
+	return runModwareCmdEnv(ctx, jl, "modware-export", opt.Args(subcmd), []string{"DBI_PASS=" + password})
+}
+
+func RunLiteratureExportCmd(ctx context.Context, jl *JobLogger, opt Options, subcmd string) error {
+	return runModwareCmd(ctx, jl, "modware-export", opt.Args(subcmd))
+}
+
+func RunLiteratureUpdateCmd(ctx context.Context, jl *JobLogger, opt Options, subcmd string) error {
+	return runModwareCmd(ctx, jl, "modware-update", opt.Args(subcmd))
+}
+
+func RunTransformCmd(ctx context.Context, jl *JobLogger, opt Options, subcmd string, file string) error {
+	// Write list of pubmed ids to the input file
+	err := ioutil.WriteFile(file, []byte("13319664\n15867862\n17246401\n"), 0644)
 	if err != nil {
-		errChan <- fmt.Errorf("Status %s message %s\n", err.Error(), string(b))
-		return
+		return fmt.Errorf("Error creating input file %s\n", file)
 	}
-	out <- []byte(cmdline)
+	return runModwareCmd(ctx, jl, "modware-transform", opt.Args(subcmd))
 }
 
-func RunLiteraturePipeCmd(fopt map[string]string, sopt map[string]string, fscmd string, scmd string, wg *sync.WaitGroup) {
-	defer wg.Done()
-	fp := make([]string, 0)
-	fp = append(fp, fscmd)
-	for k, v := range fopt {
-		fp = append(fp, fmt.Sprint("--", k), v)
-	}
-	fcmdline := strings.Join(fp, " ")
+func RunDumpCmd(ctx context.Context, jl *JobLogger, opt Options, subcmd string) error {
+	return runModwareCmd(ctx, jl, "modware-dump", opt.Args(subcmd))
+}
 
-	sp := make([]string, 0)
-	sp = append(sp, scmd)
-	for k, v := range sopt {
-		sp = append(sp, fmt.Sprint("--", k), v)
-	}
+func RunLiteraturePipeCmd(
+	ctx context.Context, jl *JobLogger,
+	fopt Options, sopt Options,
+	fscmd string, scmd string,
+) error {
+	fp := fopt.Args(fscmd)
+	fcmdline := strings.Join(fp, " ")
+	sp := sopt.Args(scmd)
 	scmdline := strings.Join(sp, " ")
 
 	fc := exec.Command("modware-export", fp...)
 	sc := exec.Command("modware-update", sp...)
 
-	// http://golang.org/pkg/io/#Pipe
-	reader, writer := io.Pipe()
-	defer reader.Close()
-	defer writer.Close()
-	var fb bytes.Buffer
-	var sb bytes.Buffer
-	// first stdout go to writer
-	// capture the errors of command if any
+	// stdout of the first command is the stdin of the second one
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("Error creating pipe %s\n", err)
+	}
 	fc.Stdout = writer
-	fc.Stderr = &fb
-	// second stdin is reader
 	sc.Stdin = reader
-	sc.Stderr = &sb
-
-	// start and wait for the commands
-	fmt.Printf("Going to run command %s\n", fcmdline)
-	if err := fc.Start(); err != nil {
-		fmt.Printf("Error starting first command: %s\n", fb.String())
-		return
-	}
-	fmt.Printf("Going to command %s\n", scmdline)
-	if err := sc.Start(); err != nil {
-		fmt.Printf("Error starting second command: %s\n", sb.String())
-		return
-	}
-	if err := fc.Wait(); err != nil {
-		fmt.Printf("Error running first command: %s\n", fb.String())
-		return
-	}
-	if err := sc.Wait(); err != nil {
-		fmt.Printf("Error running second command: %s\n", sb.String())
-		wg.Done()
-		return
-	}
-	fmt.Println("finished both commands succesfully")
-}
-
-func DbxrefCleanUpAction(c *cli.Context) error {
-	if err := ValidateCleanUpArgs(c); err != nil {
-		cli.NewExitError(err.Error(), 2)
-	}
-	in, err := os.Open(c.String("input"))
+	fout, err := jl.Attach(fc, fscmd)
 	if err != nil {
-		return cli.NewExitError(
-			fmt.Sprintf("error in opening file %s\n", err),
-			2,
-		)
+		return err
 	}
-	defer in.Close()
-	out, err := os.Create(c.String("output"))
+	defer fout.Close()
+	sout, err := jl.Attach(sc, scmd)
 	if err != nil {
-		return cli.NewExitError(
-			fmt.Sprintf("error in writing file %s\n", err),
-			2,
-		)
+		return err
 	}
-	defer out.Close()
+	defer sout.Close()
 
-	r := bufio.NewReader(in)
-	for {
-		line, err := r.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				return cli.NewExitError(
-					fmt.Sprintf("error in reading file %s\n", err),
-					2,
-				)
-			}
-
-		}
-		if strings.Contains(line, "Dbxref") {
-			gff3Slice := strings.Split(line, "\t")
-			nonxref, dbxref := splitDbxref(gff3Slice[8])
-			cdbxref := replaceDbxref(dbxref, c.StringSlice("db-name"))
-			if len(cdbxref) == 0 {
-				gff3Slice[8] = strings.TrimSuffix(nonxref, ";")
-			} else {
-				gff3Slice[8] = fmt.Sprintf("%s%s", nonxref, cdbxref)
-			}
-			fmt.Fprintf(out, "%s\n", strings.Join(gff3Slice, "\t"))
-		} else {
-			out.WriteString(line)
-		}
+	// stops the first command if the second one could not be started
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// start and wait for the commands
+	jl.Subcommand(fscmd).Infof("going to run %s", fcmdline)
+	fwait, err := runner.Start(ctx, fc)
+	writer.Close()
+	if err != nil {
+		reader.Close()
+		return fmt.Errorf("Error starting first command: %s\n", err)
 	}
-	return nil
-}
-
-func splitDbxref(attr string) (string, string) {
-	var nonxref bytes.Buffer
-	var dbxref bytes.Buffer
-	for _, s := range strings.Split(attr, ";") {
-		if strings.HasPrefix(s, "Dbxref") {
-			dbxref.WriteString(s)
-			continue
-		}
-		nonxref.WriteString(strings.TrimSuffix(s, "\n") + ";")
+	jl.Subcommand(scmd).Infof("going to run %s", scmdline)
+	swait, err := runner.Start(ctx, sc)
+	reader.Close()
+	if err != nil {
+		cancel()
+		_ = fwait()
+		return fmt.Errorf("Error starting second command: %s\n", err)
 	}
-	return nonxref.String(), dbxref.String()
-}
-
-func hasDb(dbxref string, db []string) bool {
-	for _, n := range db {
-		if strings.Contains(dbxref, n) {
-			return true
-		}
+	if err := fwait(); err != nil {
+		cancel()
+		_ = swait()
+		return fmt.Errorf("Error running first command: %s %s\n", err, fout.Tail())
 	}
-	return false
-}
-
-func hasDbPrefix(dbxref string, db []string) bool {
-	for _, n := range db {
-		if strings.HasPrefix(dbxref, n) {
-			return true
-		}
+	if err := swait(); err != nil {
+		return fmt.Errorf("Error running second command: %s %s\n", err, sout.Tail())
 	}
-	return false
+	jl.Info("finished both commands succesfully")
+	return nil
 }
 
-func replaceDbxref(dbxref string, db []string) string {
-	if !hasDb(dbxref, db) {
-		return dbxref
-	}
-
-	sm := dbRgxp.FindStringSubmatch(dbxref)
-	if sm == nil {
-		return ""
-	}
-	var fDbxref []string
-	for _, xref := range strings.Split(sm[1], ",") {
-		if !hasDbPrefix(xref, db) {
-			fDbxref = append(fDbxref, xref)
-		}
-	}
-	if len(fDbxref) == 0 {
-		return ""
-	}
-	return fmt.Sprintf("Dbxref=%s", strings.TrimSuffix(strings.Join(fDbxref, ","), "\n"))
+// runModwareCmd runs a modware program till the context is done and
+// streams its output to the job logger
+func runModwareCmd(ctx context.Context, jl *JobLogger, program string, p []string) error {
+	return runModwareCmdEnv(ctx, jl, program, p, nil)
 }
 
-func SplitPolypeptideAction(c *cli.Context) error {
-	if err := ValidatePolypetideArgs(c); err != nil {
-		return cli.NewExitError(err.Error(), 2)
+// runModwareCmdEnv runs the modware program with the extra environment
+// variables added to the environment of the wrapper
+func runModwareCmdEnv(ctx context.Context, jl *JobLogger, program string, p []string, env []string) error {
+	cmdline := strings.Join(p, " ")
+	log := jl.Subcommand(p[0])
+	log.Infof("going to run %s %s", program, cmdline)
+	cmd := exec.Command(program, p...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
 	}
-	in, err := os.Open(c.String("input"))
+	out, err := jl.Attach(cmd, p[0])
 	if err != nil {
-		return cli.NewExitError(
-			fmt.Sprintf("error in opening file %s\n", err),
-			2,
-		)
-	}
-	defer in.Close()
-	genome, poly := MakeOutputName(c.String("input"))
-	gr, err := os.Create(genome)
-	if err != nil {
-		return cli.NewExitError(
-			fmt.Sprintf("error in opening %s file  for wriring %s\n", genome, err),
-			2,
-		)
+		return err
 	}
-	defer gr.Close()
-	pr, err := os.Create(poly)
+	err = runner.Exec(ctx, cmd)
+	out.Close()
 	if err != nil {
-		return cli.NewExitError(
-			fmt.Sprintf("error in opening %s file  for wriring %s\n", genome, err),
-			2,
-		)
-	}
-	defer pr.Close()
-	r := bufio.NewReader(in)
-	fmt.Fprintf(pr, "##%s\t3\n", "gff-version")
-	seenFasta := false
-	for {
-		line, err := r.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				return cli.NewExitError(
-					fmt.Sprintf("error in reading file %s\n", genome, err),
-					2,
-				)
-			}
-		}
-		switch {
-		case seenFasta:
-			fmt.Fprint(gr, line)
-		case strings.HasPrefix(line, "###"):
-			fmt.Fprint(gr, line)
-			seenFasta = true
-		case strings.HasPrefix(line, "##"):
-			fmt.Fprint(gr, line)
-		case isPolyPeptide(line):
-			fmt.Fprint(pr, line)
-		default:
-			fmt.Fprint(gr, line)
-		}
+		return fmt.Errorf("Status %s message %s for cmdline %s\n", err, out.Tail(), cmdline)
 	}
+	log.Infof("finished running %s", cmdline)
 	return nil
 }
-
-func isPolyPeptide(line string) bool {
-	t := strings.Split(line, "\t")
-	if t[2] == "polypeptide" {
-		return true
-	}
-	return false
-}
-
-func MakeOutputName(path string) (string, string) {
-	prefix := strings.Split(filepath.Base(path), ".")[0]
-	dir := filepath.Dir(path)
-	return filepath.Join(dir, fmt.Sprintf("%s_no_poly.gff3", prefix)),
-		filepath.Join(dir, fmt.Sprintf("%s_poly.gff3", prefix))
-}
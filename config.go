@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -44,6 +46,18 @@ type GFF3Config struct {
 	LogFile  string `yaml:"logfile"`
 }
 
+type GeneSummaryConfig struct {
+	Dsn       string `yaml:"dsn"`
+	User      string `yaml:"user"`
+	Password  string `yaml:"password"`
+	Ldsn      string `yaml:"legacy_dsn"`
+	Luser     string `yaml:"legacy_user"`
+	Lpassword string `yaml:"legacy_password"`
+	Output    string `yaml:"output"`
+	LogLevel  string `yaml:"log_level"`
+	LogFile   string `yaml:"logfile"`
+}
+
 type StockCenterConfig struct {
 	Dsn       string `yaml:"dsn"`
 	User      string `yaml:"user"`
@@ -56,24 +70,45 @@ type StockCenterConfig struct {
 	Dir       string `yaml:"dir"`
 }
 
-func CreateYamlFile(in interface{}, c *cli.Context, name string) string {
+// MarshalConfig renders a config as yaml, the keys follow the order of the
+// struct fields so the same config always gives the same bytes
+func MarshalConfig(in interface{}) ([]byte, error) {
 	b, err := yaml.Marshal(in)
 	if err != nil {
-		log.Fatal(err)
+		return b, fmt.Errorf("unable to encode config %s", err)
+	}
+	if !bytes.HasSuffix(b, []byte("\n")) {
+		b = append(b, '\n')
 	}
-	p := filepath.Join(c.String("config-folder"), fmt.Sprint(name, ".yaml"))
-	cf, err := os.Create(p)
-	defer cf.Close()
+	return b, nil
+}
+
+func CreateYamlFile(in interface{}, c *cli.Context, name string) string {
+	b, err := MarshalConfig(in)
 	if err != nil {
 		log.Fatal(err)
 	}
-	_, err = fmt.Fprint(cf, string(b))
-	if err != nil {
+	p := filepath.Join(configFolder(c), fmt.Sprint(name, ".yaml"))
+	// the config has the credentials, a leftover file is removed so that
+	// the new one is only readable by the owner
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, b, 0600); err != nil {
 		log.Fatal(err)
 	}
 	return p
 }
 
+// configFolder is the folder of the generated config files, the temp
+// folder is used if the command has none
+func configFolder(c *cli.Context) string {
+	if len(c.String("config-folder")) > 0 {
+		return c.String("config-folder")
+	}
+	return os.TempDir()
+}
+
 func MakePub2BibConfig(c *cli.Context, name string) string {
 	pconf := Pub2BibConfig{
 		Output:    filepath.Join(c.String("output-folder"), fmt.Sprint(name, ".bib")),
@@ -157,3 +192,18 @@ func MakeConfigFile(c *cli.Context, name string) string {
 	}
 	return CreateYamlFile(gconf, c, name)
 }
+
+func MakeGeneSummaryConfig(c *cli.Context, name string) string {
+	gconf := GeneSummaryConfig{
+		Dsn:       c.String("dsn"),
+		User:      c.String("user"),
+		Password:  c.String("password"),
+		Ldsn:      c.String("legacy-dsn"),
+		Luser:     c.String("legacy-user"),
+		Lpassword: c.String("legacy-password"),
+		Output:    filepath.Join(c.String("output-folder"), fmt.Sprint(name, ".csv")),
+		LogFile:   filepath.Join(c.String("log-folder"), fmt.Sprint(name, ".log")),
+		LogLevel:  "info",
+	}
+	return CreateYamlFile(gconf, c, name)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func configContext(t *testing.T) *cli.Context {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	values := map[string]string{
+		"dsn":             "dbi:Oracle:host=oracle;sid=orasid",
+		"user":            "chado",
+		"password":        "chadopass",
+		"muser":           "modware",
+		"mpassword":       "modwarepass",
+		"legacy-dsn":      "dbi:Oracle:host=legacy;sid=legsid",
+		"legacy-user":     "cgm_ddb",
+		"legacy-password": "legacypass",
+		"email":           "dictybase@northwestern.edu",
+		"output-folder":   "/data/output",
+		"log-folder":      "/data/log",
+		"config-folder":   t.TempDir(),
+	}
+	for k, v := range values {
+		set.String(k, v, "")
+	}
+	return cli.NewContext(nil, set, nil)
+}
+
+func TestMakeConfigGolden(t *testing.T) {
+	c := configContext(t)
+	cases := map[string]func() string{
+		"pub2bib": func() string { return MakePub2BibConfig(c, "dictygenomespub") },
+		"custom": func() string {
+			return MakeCustomConfigFile(c, "dicty_curated", "canonical_gff3")
+		},
+		"stockcenter": func() string { return MakeSCConfig(c, "dictystrain") },
+		"literature":  func() string { return MakeLiteatureConfig(c, "dictybib") },
+		"dicty": func() string {
+			return MakeDictyConfigFile(c, "dicty_canonical", "canonical_gff3")
+		},
+		"general": func() string { return MakeGeneralConfigFile(c, "genesummary", "csv") },
+		"gff3":    func() string { return MakeConfigFile(c, "dicty_gff3") },
+		"genesummary": func() string {
+			return MakeGeneSummaryConfig(c, "genesummary")
+		},
+	}
+	for name, fn := range cases {
+		fn := fn
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			path := fn()
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(os.FileMode(0600), info.Mode().Perm(), "should only be readable by the owner")
+			first, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			second, err := ioutil.ReadFile(fn())
+			require.NoError(t, err)
+			assert.Equal(first, second, "should generate identical config on every run")
+			golden := filepath.Join("testdata", "golden", name+".yaml")
+			if *update {
+				require.NoError(t, ioutil.WriteFile(golden, first, 0644))
+			}
+			expected, err := ioutil.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(string(expected), string(first), "should match the golden file")
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/migration-data-export/dbprofile"
+	"github.com/urfave/cli"
+)
+
+// connectionFlags are the dsn flags of the commands along with
+// their user and password flags
+var connectionFlags = [][3]string{
+	{"dsn", "user", "password"},
+	{"legacy-dsn", "legacy-user", "legacy-password"},
+}
+
+// ResolveConnections turns the dsn flags of the command into the DBI dsn
+// that modware expects. A dsn could be given in any form that is
+// understood by dbprofile or as the name of a profile in the profile
+// file. The user and password of the profile are used unless they are
+// given, the host, port and sid flags are filled in for the commands that
+// connect directly.
+func ResolveConnections(c *cli.Context) error {
+	for _, cf := range connectionFlags {
+		dsn, user, password := cf[0], cf[1], cf[2]
+		if c.Generic(dsn) == nil || len(c.String(dsn)) == 0 {
+			continue
+		}
+		p, err := dbprofile.Resolve(c.String(dsn), c.GlobalString("profile-file"))
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("invalid %s %s", dsn, err), 2)
+		}
+		values := [][2]string{{dsn, p.DBI()}}
+		if c.Generic(user) != nil && len(c.String(user)) == 0 {
+			values = append(values, [2]string{user, p.User})
+		}
+		if c.Generic(password) != nil && len(c.String(password)) == 0 {
+			values = append(values, [2]string{password, p.Password})
+		}
+		if dsn == "dsn" && c.Generic("host") != nil && len(c.String("host")) == 0 {
+			sid := p.SID
+			if len(sid) == 0 {
+				sid = p.Service
+			}
+			values = append(
+				values,
+				[2]string{"host", p.Host},
+				[2]string{"port", strconv.Itoa(p.Port)},
+				[2]string{"sid", sid},
+			)
+		}
+		for _, v := range values {
+			if err := c.Set(v[0], v[1]); err != nil {
+				return cli.NewExitError(fmt.Sprintf("unable to set %s %s", v[0], err), 2)
+			}
+		}
+	}
+	return nil
+}
+
+// connectionProfile is the profile of the host, port and sid flags
+// of the command
+func connectionProfile(c *cli.Context) (*dbprofile.Profile, error) {
+	p := &dbprofile.Profile{
+		Host:     c.String("host"),
+		SID:      c.String("sid"),
+		User:     c.String("user"),
+		Password: c.String("password"),
+	}
+	if len(c.String("port")) > 0 {
+		port, err := strconv.Atoi(c.String("port"))
+		if err != nil {
+			return p, fmt.Errorf("invalid port %s", err)
+		}
+		p.Port = port
+	}
+	return p, p.Validate()
+}
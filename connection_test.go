@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+func TestResolveConnections(t *testing.T) {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, name := range []string{"dsn", "user", "password", "host", "port", "sid"} {
+		set.String(name, "", "")
+	}
+	set.String("legacy-dsn", "dbi:Oracle:host=legacy;sid=orcl", "")
+	set.String("legacy-user", "CGM_DDB", "")
+	require.NoError(t, set.Parse([]string{"-dsn", "CGM_CHADO/secret@dicty-oracle:1522/orcl"}))
+	c := cli.NewContext(nil, set, nil)
+	require.NoError(t, ResolveConnections(c))
+	assert := assert.New(t)
+	assert.Equal("dbi:Oracle:host=dicty-oracle;port=1522;service_name=orcl", c.String("dsn"))
+	assert.Equal("CGM_CHADO", c.String("user"), "should take the user of the dsn")
+	assert.Equal("secret", c.String("password"), "should take the password of the dsn")
+	assert.Equal("dicty-oracle", c.String("host"))
+	assert.Equal("1522", c.String("port"))
+	assert.Equal("orcl", c.String("sid"))
+	assert.Equal("dbi:Oracle:host=legacy;port=1521;sid=orcl", c.String("legacy-dsn"))
+	assert.Equal("CGM_DDB", c.String("legacy-user"), "should keep the given user")
+
+	bad := flag.NewFlagSet("test", flag.ContinueOnError)
+	bad.String("dsn", "dbi:Oracle:ORCL", "")
+	assert.Error(ResolveConnections(cli.NewContext(nil, bad, nil)))
+}
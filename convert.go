@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/migration-data-export/gff3"
+	"github.com/urfave/cli"
+)
+
+// output formats of convert-gff3
+var convertFormats = map[string]func(io.Writer, []*gff3.Gene) error{
+	"gtf":   writeGTF,
+	"bed":   writeBED,
+	"jsonl": writeGeneJSON,
+}
+
+// ConvertGFF3 writes the genes of the gff3 in the given format and returns
+// the number of transcripts, features that are not part of a transcript
+// are left out
+func ConvertGFF3(in io.Reader, out io.Writer, format string, so *gff3.Ontology) (int, error) {
+	write, ok := convertFormats[format]
+	if !ok {
+		return 0, fmt.Errorf("unknown format %s", format)
+	}
+	var features []*gff3.Feature
+	err := transformGFF3(in, func(rec *gff3.Record) error {
+		if rec.Kind == gff3.FeatureKind {
+			features = append(features, rec.Feature)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	genes := gff3.NewIndex(features).Genes(so)
+	w := bufio.NewWriter(out)
+	if err := write(w, genes); err != nil {
+		return 0, fmt.Errorf("error in writing %s %s", format, err)
+	}
+	count := 0
+	for _, g := range genes {
+		count += len(g.Transcripts)
+	}
+	return count, w.Flush()
+}
+
+// phaseOf is the phase of a coding segment, a missing phase is taken as 0
+func phaseOf(f *gff3.Feature) int {
+	p, err := strconv.Atoi(f.Phase)
+	if err != nil {
+		return 0
+	}
+	return p
+}
+
+// transcriptOrder returns the segments in the direction of the transcript
+func transcriptOrder(parts []*gff3.Feature, strand string) []*gff3.Feature {
+	ordered := append([]*gff3.Feature{}, parts...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if strand == "-" {
+			return ordered[i].Start > ordered[j].Start
+		}
+		return ordered[i].Start < ordered[j].Start
+	})
+	return ordered
+}
+
+// spliceSlice cuts the bases from offset to offset+length out of the
+// segments that are in transcript order. The pieces get the given type
+// and the frame that is counted from the start of the slice shifted by
+// the phase.
+func spliceSlice(ordered []*gff3.Feature, strand, typ string, offset, length, phase int) []*gff3.Feature {
+	var pieces []*gff3.Feature
+	pos := 0
+	for _, seg := range ordered {
+		from, to := offset-pos, offset+length-pos
+		pos += seg.Len()
+		if from < 0 {
+			from = 0
+		}
+		if to > seg.Len() {
+			to = seg.Len()
+		}
+		if from >= to {
+			continue
+		}
+		p := *seg
+		p.Type = typ
+		p.Attributes = nil
+		done := pos - seg.Len() + from - offset
+		p.Phase = strconv.Itoa(((3-(done-phase)%3)%3 + 3) % 3)
+		if strand == "-" {
+			p.Start, p.End = seg.End-to+1, seg.End-from
+		} else {
+			p.Start, p.End = seg.Start+from, seg.Start+to-1
+		}
+		pieces = append(pieces, &p)
+	}
+	return pieces
+}
+
+func gtfAttributes(g *gff3.Gene, t *gff3.Transcript) string {
+	clean := func(s string) string { return strings.Replace(s, `"`, "", -1) }
+	attrs := fmt.Sprintf(`gene_id "%s"; transcript_id "%s";`, clean(g.Feature.ID()), clean(t.Feature.ID()))
+	if name, ok := g.Feature.Attributes.First("Name"); ok {
+		attrs += fmt.Sprintf(` gene_name "%s";`, clean(name))
+	}
+	return attrs
+}
+
+// gtfUTR names the UTR in gtf, a UTR of unknown side is placed by its
+// position to the coding range
+func gtfUTR(u *gff3.Feature, t *gff3.Transcript) string {
+	switch u.Type {
+	case "five_prime_UTR":
+		return "5UTR"
+	case "three_prime_UTR":
+		return "3UTR"
+	}
+	start, _, ok := t.CodingRange()
+	if !ok || (u.Start < start) == (t.Feature.Strand != "-") {
+		return "5UTR"
+	}
+	return "3UTR"
+}
+
+// writeGTF writes the transcripts in GTF2.2, the CDS is written without
+// the stop codon, which is taken as the last three coding bases
+func writeGTF(w io.Writer, genes []*gff3.Gene) error {
+	for _, g := range genes {
+		for _, t := range g.Transcripts {
+			strand := t.Feature.Strand
+			lines := append([]*gff3.Feature{}, t.Exons...)
+			ordered := transcriptOrder(t.CDS, strand)
+			total := 0
+			for _, c := range ordered {
+				total += c.Len()
+			}
+			switch {
+			case total >= 6:
+				phase := phaseOf(ordered[0])
+				lines = append(lines, spliceSlice(ordered, strand, "CDS", 0, total-3, phase)...)
+				if phase == 0 {
+					lines = append(lines, spliceSlice(ordered, strand, "start_codon", 0, 3, 0)...)
+				}
+				lines = append(lines, spliceSlice(ordered, strand, "stop_codon", total-3, 3, 0)...)
+			case total > 0:
+				lines = append(lines, spliceSlice(ordered, strand, "CDS", 0, total, phaseOf(ordered[0]))...)
+			}
+			for _, u := range t.UTRs {
+				p := *u
+				p.Type = gtfUTR(u, t)
+				lines = append(lines, &p)
+			}
+			attrs := gtfAttributes(g, t)
+			for _, l := range lines {
+				frame := l.Phase
+				if l.Type == "exon" || strings.HasSuffix(l.Type, "UTR") {
+					frame = "."
+				}
+				_, err := fmt.Fprintf(
+					w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\n",
+					l.SeqID, l.Source, l.Type, l.Start, l.End, l.Score, strand, frame, attrs,
+				)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writeBED writes a BED12 line for every transcript with the exons as
+// blocks and the coding range as the thick part
+func writeBED(w io.Writer, genes []*gff3.Gene) error {
+	for _, g := range genes {
+		for _, t := range g.Transcripts {
+			exons := t.Exons
+			start, end := exons[0].Start-1, exons[0].End
+			for _, e := range exons[1:] {
+				if e.End > end {
+					end = e.End
+				}
+			}
+			thickStart, thickEnd := start, start
+			if cs, ce, ok := t.CodingRange(); ok {
+				thickStart, thickEnd = cs-1, ce
+			}
+			var sizes, starts strings.Builder
+			for _, e := range exons {
+				fmt.Fprintf(&sizes, "%d,", e.Len())
+				fmt.Fprintf(&starts, "%d,", e.Start-1-start)
+			}
+			strand := t.Feature.Strand
+			if strand != "+" && strand != "-" {
+				strand = "."
+			}
+			_, err := fmt.Fprintf(
+				w, "%s\t%d\t%d\t%s\t0\t%s\t%d\t%d\t0\t%d\t%s\t%s\n",
+				t.Feature.SeqID, start, end, t.Feature.ID(), strand,
+				thickStart, thickEnd, len(exons), sizes.String(), starts.String(),
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GeneDocument is the json document of a gene with its transcripts
+type GeneDocument struct {
+	ID          string                `json:"id"`
+	SeqID       string                `json:"seqid"`
+	Source      string                `json:"source"`
+	Type        string                `json:"type"`
+	Start       int                   `json:"start"`
+	End         int                   `json:"end"`
+	Strand      string                `json:"strand"`
+	Attributes  map[string][]string   `json:"attributes,omitempty"`
+	Transcripts []*TranscriptDocument `json:"transcripts"`
+}
+
+// TranscriptDocument is a transcript with its parts
+type TranscriptDocument struct {
+	ID         string              `json:"id"`
+	Type       string              `json:"type"`
+	Start      int                 `json:"start"`
+	End        int                 `json:"end"`
+	Attributes map[string][]string `json:"attributes,omitempty"`
+	Exons      []*PartDocument     `json:"exons"`
+	CDS        []*PartDocument     `json:"cds,omitempty"`
+	UTRs       []*PartDocument     `json:"utrs,omitempty"`
+}
+
+// PartDocument is an exon, coding segment or UTR
+type PartDocument struct {
+	Type  string `json:"type"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Phase string `json:"phase,omitempty"`
+}
+
+// documentAttributes are the attributes without the ones that link
+// the features
+func documentAttributes(f *gff3.Feature) map[string][]string {
+	attrs := make(map[string][]string)
+	for _, a := range f.Attributes {
+		if a.Tag == "ID" || a.Tag == "Parent" {
+			continue
+		}
+		attrs[a.Tag] = a.Values
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+	return attrs
+}
+
+func partDocuments(parts []*gff3.Feature) []*PartDocument {
+	var docs []*PartDocument
+	for _, p := range parts {
+		d := &PartDocument{Type: p.Type, Start: p.Start, End: p.End}
+		if p.Phase != "." {
+			d.Phase = p.Phase
+		}
+		docs = append(docs, d)
+	}
+	return docs
+}
+
+// writeGeneJSON writes a json document per gene on a line
+func writeGeneJSON(w io.Writer, genes []*gff3.Gene) error {
+	enc := json.NewEncoder(w)
+	for _, g := range genes {
+		f := g.Feature
+		doc := &GeneDocument{
+			ID:         f.ID(),
+			SeqID:      f.SeqID,
+			Source:     f.Source,
+			Type:       f.Type,
+			Start:      f.Start,
+			End:        f.End,
+			Strand:     f.Strand,
+			Attributes: documentAttributes(f),
+		}
+		for _, t := range g.Transcripts {
+			doc.Transcripts = append(doc.Transcripts, &TranscriptDocument{
+				ID:         t.Feature.ID(),
+				Type:       t.Feature.Type,
+				Start:      t.Feature.Start,
+				End:        t.Feature.End,
+				Attributes: documentAttributes(t.Feature),
+				Exons:      partDocuments(t.Exons),
+				CDS:        partDocuments(t.CDS),
+				UTRs:       partDocuments(t.UTRs),
+			})
+		}
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ConvertGFF3Action(c *cli.Context) error {
+	for _, p := range []string{"input", "output"} {
+		if !c.IsSet(p) {
+			return cli.NewExitError(fmt.Sprintf("argument %s is required", p), 2)
+		}
+	}
+	if _, ok := convertFormats[c.String("format")]; !ok {
+		return cli.NewExitError(fmt.Sprintf("unknown format %s", c.String("format")), 2)
+	}
+	in, err := os.Open(c.String("input"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("error in opening file %s", err), 2)
+	}
+	defer in.Close()
+	out, err := os.Create(c.String("output"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("error in writing file %s", err), 2)
+	}
+	defer out.Close()
+	count, err := ConvertGFF3(in, out, c.String("format"), gff3.DefaultOntology())
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	getLogger(c).Infof("converted %d transcripts of %s to %s", count, c.String("input"), c.String("format"))
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/migration-data-export/gff3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertGFF3(t *testing.T) {
+	for _, format := range []string{"gtf", "bed", "jsonl"} {
+		t.Run(format, func(t *testing.T) {
+			in, err := os.Open(filepath.Join("testdata", "gff3", "convert.gff3"))
+			require.NoError(t, err)
+			defer in.Close()
+			var out bytes.Buffer
+			count, err := ConvertGFF3(in, &out, format, gff3.DefaultOntology())
+			require.NoError(t, err)
+			assert.Equal(t, 4, count)
+			assert.Equal(t, readFixture(t, "convert."+format), out.String())
+		})
+	}
+	t.Run("unknown format", func(t *testing.T) {
+		var out bytes.Buffer
+		_, err := ConvertGFF3(bytes.NewBufferString("##gff-version 3\n"), &out, "gtf3", gff3.DefaultOntology())
+		assert.Error(t, err)
+	})
+}
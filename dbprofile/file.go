@@ -0,0 +1,130 @@
+package dbprofile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v1"
+)
+
+// entry is a profile in the yaml file, the connection could be given as a
+// dsn in any of the supported forms, the other fields take precedence over
+// the dsn
+type entry struct {
+	Dsn          string `yaml:"dsn"`
+	Host         string `yaml:"host"`
+	Port         int    `yaml:"port"`
+	SID          string `yaml:"sid"`
+	Service      string `yaml:"service"`
+	User         string `yaml:"user"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+// Profiles are the named connection profiles of a yaml file
+type Profiles map[string]*Profile
+
+// ParseProfiles reads named profiles from yaml, for example
+//
+//	chado:
+//	  dsn: dbi:Oracle:host=oracle;port=1521;sid=orcl
+//	  user: CGM_CHADO
+//	  password_file: /run/secrets/oracle_pass
+//	legacy:
+//	  host: oracle
+//	  sid: orcl
+//	  user: CGM_DDB
+func ParseProfiles(b []byte) (Profiles, error) {
+	entries := make(map[string]entry)
+	if err := yaml.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("unable to decode profiles %s", err)
+	}
+	profiles := make(Profiles)
+	for name, e := range entries {
+		p := &Profile{
+			Name:     name,
+			Host:     e.Host,
+			Port:     e.Port,
+			SID:      e.SID,
+			Service:  e.Service,
+			User:     e.User,
+			Password: e.Password,
+		}
+		if len(e.PasswordFile) > 0 && len(p.Password) == 0 {
+			b, err := ioutil.ReadFile(e.PasswordFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read password of profile %s %s", name, err)
+			}
+			p.Password = strings.TrimRight(string(b), "\r\n")
+		}
+		if len(e.Dsn) > 0 {
+			dp, err := Parse(e.Dsn)
+			if err != nil {
+				return nil, fmt.Errorf("invalid dsn of profile %s %s", name, err)
+			}
+			p.Merge(dp)
+		}
+		if p.Port == 0 {
+			p.Port = DefaultPort
+		}
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// LoadProfiles reads the named profiles of a yaml file
+func LoadProfiles(path string) (Profiles, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read profiles %s", err)
+	}
+	return ParseProfiles(b)
+}
+
+// Names returns the sorted names of the profiles
+func (ps Profiles) Names() []string {
+	names := make([]string, 0)
+	for n := range ps {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the profile of the given name from the profile file or
+// else parses the value as a connection string. The profile file is
+// optional.
+func Resolve(value, file string) (*Profile, error) {
+	if len(file) > 0 {
+		profiles, err := LoadProfiles(file)
+		if err != nil {
+			return nil, err
+		}
+		if p, ok := profiles[value]; ok {
+			cp := *p
+			return &cp, nil
+		}
+	}
+	return Parse(value)
+}
+
+// FromValues is the profile of the separately given host, port, sid or
+// service, user and password, the values that are left empty are filled
+// in from the dsn, which is resolved as in Resolve. The profile is
+// validated.
+func FromValues(values Profile, dsn, file string) (*Profile, error) {
+	p := values
+	if len(dsn) > 0 {
+		dp, err := Resolve(dsn, file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dsn %s", err)
+		}
+		p.Merge(dp)
+	}
+	if p.Port == 0 {
+		p.Port = DefaultPort
+	}
+	return &p, p.Validate()
+}
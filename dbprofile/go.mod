@@ -0,0 +1,8 @@
+module github.com/migration-data-export/dbprofile
+
+go 1.16
+
+require (
+	github.com/stretchr/testify v1.10.0
+	gopkg.in/yaml.v1 v1.0.0-20140924161607-9f9df34309c0
+)
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// watermarkFile is the name of the file that keeps the watermarks of the
+// incremental exports of a command, every command sharing the folder gets
+// its own
+func watermarkFile(command string) string {
+	return fmt.Sprintf("watermark.%s.json", command)
+}
+
+// Watermark is the start of the last successful run of an export along
+// with the keys of all the rows it found, the keys that are missing in
+// the next run are written as tombstones
+type Watermark struct {
+	Updated time.Time `json:"updated"`
+	Keys    []string  `json:"keys"`
+}
+
+// WatermarkStore keeps the watermark of every export in a json file
+type WatermarkStore struct {
+	mu      sync.Mutex
+	path    string
+	Exports map[string]*Watermark `json:"exports"`
+}
+
+// OpenWatermarkStore reads the watermark file of the command in the folder,
+// an empty store is returned if the file does not exist yet
+func OpenWatermarkStore(folder, command string) (*WatermarkStore, error) {
+	ws := &WatermarkStore{
+		path:    filepath.Join(folder, watermarkFile(command)),
+		Exports: make(map[string]*Watermark),
+	}
+	b, err := ioutil.ReadFile(ws.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ws, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read watermark %s", err)
+	}
+	if err := json.Unmarshal(b, ws); err != nil {
+		return nil, fmt.Errorf("unable to decode watermark %s", err)
+	}
+	if ws.Exports == nil {
+		ws.Exports = make(map[string]*Watermark)
+	}
+	return ws, nil
+}
+
+// Get returns the watermark of an export, nil if it never completed
+func (ws *WatermarkStore) Get(name string) *Watermark {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.Exports[name]
+}
+
+// Set saves the watermark of an export
+func (ws *WatermarkStore) Set(name string, wm *Watermark) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.Exports[name] = wm
+	b, err := json.MarshalIndent(ws, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode watermark %s", err)
+	}
+	return writeFileAtomic(ws.path, b)
+}
+
+// ParseSince reads the value of the since flag, a timestamp, a date or
+// last for the start of the last successful run. A zero time is returned
+// for an empty value or an export without a watermark, which means
+// every row is exported.
+func ParseSince(value string, wm *Watermark) (time.Time, error) {
+	switch value {
+	case "":
+		return time.Time{}, nil
+	case "last":
+		if wm == nil {
+			return time.Time{}, nil
+		}
+		return wm.Updated, nil
+	}
+	for _, l := range []string{layout, "2006-01-02"} {
+		if t, err := time.ParseInLocation(l, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("since %s is neither last nor a timestamp like %s", value, layout)
+}
+
+// sinceClause is the condition on the modification date of the rows of
+// an incremental export, empty if every row is exported
+func sinceClause(column string, since time.Time) (string, []interface{}) {
+	if since.IsZero() {
+		return "", nil
+	}
+	return fmt.Sprintf("WHERE %s >= :1", column), []interface{}{since}
+}
+
+// tombstoneFile is the file with the keys of the rows of an export
+// that disappeared since the last run
+func tombstoneFile(folder, name string) string {
+	return filepath.Join(folder, name+"_tombstones.csv")
+}
+
+// readKeys returns the keys from the first column of the query
+func readKeys(ctx context.Context, dbh *sql.DB, query string) ([]string, error) {
+	rows, err := dbh.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("unable to run query %s", err)
+	}
+	defer rows.Close()
+	keys := make([]string, 0)
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, fmt.Errorf("unable to scan the next row %s", err)
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to close the rows %s", err)
+	}
+	return keys, nil
+}
+
+// WriteTombstones writes the keys of the previous run that are not among
+// the current ones, a key per line in sorted order
+func WriteTombstones(path string, previous, current []string) (int, error) {
+	seen := make(map[string]bool)
+	for _, k := range current {
+		seen[k] = true
+	}
+	gone := make([]string, 0)
+	for _, k := range previous {
+		if !seen[k] {
+			seen[k] = true
+			gone = append(gone, k)
+		}
+	}
+	sort.Strings(gone)
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("unable to open file %s", err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	for _, k := range gone {
+		if err := w.Write([]string{k}); err != nil {
+			return 0, fmt.Errorf("unable to write csv row %s", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return 0, fmt.Errorf("unable to finish csv writing %s", err)
+	}
+	return len(gone), nil
+}
+
+// deltaExport is an export of the rows modified since a time, the keys
+// of all the rows are read afterwards to write the tombstones and the
+// next watermark
+type deltaExport struct {
+	name  string
+	keys  string
+	since time.Time
+	start time.Time
+	store *WatermarkStore
+}
+
+// newDeltaExport resolves the since flag of the command for an export
+func newDeltaExport(c *cli.Context, store *WatermarkStore, name, keys string) (*deltaExport, error) {
+	since, err := ParseSince(c.String("since"), store.Get(name))
+	if err != nil {
+		return nil, err
+	}
+	if c.String("since") == "last" && since.IsZero() {
+		getLogger(c).Warnf("no watermark found for %s, exporting every row", name)
+	}
+	return &deltaExport{name: name, keys: keys, since: since, start: time.Now(), store: store}, nil
+}
+
+// deltaOutputs are the files of an export in the folder, the tombstones
+// are listed whenever the since flag is given as they are only written by
+// an incremental export
+func deltaOutputs(c *cli.Context, folder, name string, files ...string) []string {
+	if len(c.String("since")) == 0 {
+		return files
+	}
+	return append(files, tombstoneFile(folder, name))
+}
+
+// finish writes the tombstones of an incremental export and saves the
+// keys of all the rows as the new watermark
+func (d *deltaExport) finish(ctx context.Context, c *cli.Context, dbh *sql.DB, folder string) error {
+	keys, err := readKeys(ctx, dbh, d.keys)
+	if err != nil {
+		return err
+	}
+	if !d.since.IsZero() {
+		var previous []string
+		if wm := d.store.Get(d.name); wm != nil {
+			previous = wm.Keys
+		}
+		n, err := WriteTombstones(tombstoneFile(folder, d.name), previous, keys)
+		if err != nil {
+			return err
+		}
+		getLogger(c).Infof("wrote %d tombstones of %s", n, d.name)
+	}
+	return d.store.Set(d.name, &Watermark{Updated: d.start, Keys: keys})
+}
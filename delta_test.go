@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+func TestParseSince(t *testing.T) {
+	last := time.Date(2020, 5, 6, 7, 8, 9, 0, time.UTC)
+	cases := []struct {
+		value string
+		wm    *Watermark
+		want  time.Time
+	}{
+		{"", &Watermark{Updated: last}, time.Time{}},
+		{"last", &Watermark{Updated: last}, last},
+		{"last", nil, time.Time{}},
+		{"2019-02-03 04:05:06", nil, time.Date(2019, 2, 3, 4, 5, 6, 0, time.Local)},
+		{"2019-02-03", nil, time.Date(2019, 2, 3, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := ParseSince(tc.value, tc.wm)
+			require.NoError(t, err)
+			assert.True(t, tc.want.Equal(got), "expected %s got %s", tc.want, got)
+		})
+	}
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseSince("yesterday", nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestWatermarkStore(t *testing.T) {
+	dir := t.TempDir()
+	ws, err := OpenWatermarkStore(dir, "dsc-orders")
+	require.NoError(t, err)
+	assert.Nil(t, ws.Get("stock_orders"))
+	users, err := OpenWatermarkStore(dir, "dsc-users")
+	require.NoError(t, err)
+	wm := &Watermark{Updated: time.Date(2020, 5, 6, 7, 8, 9, 0, time.UTC), Keys: []string{"11", "12"}}
+	require.NoError(t, ws.Set("stock_orders", wm))
+	uwm := &Watermark{Updated: time.Date(2020, 5, 6, 7, 9, 0, 0, time.UTC), Keys: []string{"DBS0236123"}}
+	require.NoError(t, users.Set("strain_user_annotations", uwm))
+	ws, err = OpenWatermarkStore(dir, "dsc-orders")
+	require.NoError(t, err)
+	assert.Equal(t, wm, ws.Get("stock_orders"), "should not be overwritten by another command")
+	users, err = OpenWatermarkStore(dir, "dsc-users")
+	require.NoError(t, err)
+	assert.Equal(t, uwm, users.Get("strain_user_annotations"))
+	tmp, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, tmp, "should not leave temporary files behind")
+}
+
+func TestWriteTombstones(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stock_orders_tombstones.csv")
+	n, err := WriteTombstones(path, []string{"14", "11", "12", "13"}, []string{"12", "15"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "11\n13\n14\n", string(b))
+}
+
+func deltaContext(t *testing.T, since string) *cli.Context {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("since", since, "")
+	return cli.NewContext(nil, set, nil)
+}
+
+func TestDeltaOrders(t *testing.T) {
+	orders := testOrders()
+	fdb := orderFixture(orders)
+	// orders placed since the bound of the query
+	fdb.tables[fmt.Sprintf(listOrders, "WHERE sorder.order_date >= :1")] = func(args []driver.Value) (*fixtureTable, error) {
+		since := args[0].(time.Time)
+		t := &fixtureTable{columns: []string{"email", "order_date", "stock_order_id"}}
+		for _, o := range orders {
+			if !o.Date.Before(since) {
+				t.rows = append(t.rows, []driver.Value{o.Email, o.Date, o.ID})
+			}
+		}
+		return t, nil
+	}
+	keys := []string{"11", "12"}
+	fdb.tables[orderKeys] = func([]driver.Value) (*fixtureTable, error) {
+		t := &fixtureTable{columns: []string{"stock_order_id"}}
+		for _, k := range keys {
+			t.rows = append(t.rows, []driver.Value{k})
+		}
+		return t, nil
+	}
+	dbh := openFixtureDB(t, fdb)
+	dir := t.TempDir()
+	store, err := OpenWatermarkStore(dir, "dsc-orders")
+	require.NoError(t, err)
+	read := func(d *deltaExport) []int64 {
+		var ids []int64
+		err := ReadOrdersSince(context.Background(), dbh, d.since, func(o *Order) error {
+			ids = append(ids, o.ID)
+			return nil
+		})
+		require.NoError(t, err)
+		return ids
+	}
+
+	d, err := newDeltaExport(deltaContext(t, "last"), store, "stock_orders", orderKeys)
+	require.NoError(t, err)
+	assert.True(t, d.since.IsZero(), "the first run should export every order")
+	assert.Equal(t, []int64{11, 12}, read(d))
+	require.NoError(t, d.finish(context.Background(), deltaContext(t, "last"), dbh, dir))
+	assert.NoFileExists(t, tombstoneFile(dir, "stock_orders"))
+	assert.Equal(t, keys, store.Get("stock_orders").Keys)
+
+	keys = []string{"12"}
+	d, err = newDeltaExport(deltaContext(t, "2012-01-01"), store, "stock_orders", orderKeys)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{12}, read(d))
+	assert.Equal(
+		t,
+		[]string{"a.csv", tombstoneFile(dir, "stock_orders")},
+		deltaOutputs(deltaContext(t, "2012-01-01"), dir, "stock_orders", "a.csv"),
+	)
+	assert.Equal(t, []string{"a.csv"}, deltaOutputs(deltaContext(t, ""), dir, "stock_orders", "a.csv"))
+	require.NoError(t, d.finish(context.Background(), deltaContext(t, "2012-01-01"), dbh, dir))
+	b, err := ioutil.ReadFile(tombstoneFile(dir, "stock_orders"))
+	require.NoError(t, err)
+	assert.Equal(t, "11\n", string(b))
+	assert.Equal(t, keys, store.Get("stock_orders").Keys)
+}
+
+func TestDeltaDryRun(t *testing.T) {
+	global := flag.NewFlagSet("global", flag.ContinueOnError)
+	global.Bool("dry-run", true, "")
+	global.String("plan-format", "json", "")
+	dir := t.TempDir()
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("since", "last", "")
+	set.String("format", "legacy", "")
+	set.String("unresolved", KeepUnresolved, "")
+	set.String("output-folder", dir, "")
+	c := cli.NewContext(nil, set, cli.NewContext(nil, global, nil))
+	previous := outfolder
+	outfolder = filepath.Join(dir, "stockcenter")
+	defer func() { outfolder = previous }()
+	for name, action := range map[string]func(*cli.Context) error{
+		"dsc-orders": DscOrderAction,
+		"dsc-users":  DscUsersAction,
+	} {
+		c.Command = cli.Command{Name: name}
+		require.NoError(t, action(c), name)
+	}
+	assert.NoDirExists(t, outfolder, "should not create the output folder")
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	assert.Empty(t, files, "should not write a watermark")
+}
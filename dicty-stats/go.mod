@@ -3,6 +3,7 @@ module github.com/dictybase/dictystats
 go 1.21.10
 
 require (
+	github.com/migration-data-export/dbprofile v0.0.0-00010101000000-000000000000
 	github.com/sijms/go-ora/v2 v2.8.19
 	github.com/urfave/cli/v2 v2.27.2
 )
@@ -11,4 +12,7 @@ require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.4 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20240312152122-5f08fbb34913 // indirect
+	gopkg.in/yaml.v1 v1.0.0-20140924161607-9f9df34309c0 // indirect
 )
+
+replace github.com/migration-data-export/dbprofile => ../dbprofile
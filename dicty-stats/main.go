@@ -6,7 +6,8 @@ import (
 	"fmt"
 	"os"
 
-	go_ora "github.com/sijms/go-ora/v2"
+	"github.com/migration-data-export/dbprofile"
+	_ "github.com/sijms/go-ora/v2"
 	"github.com/urfave/cli/v2"
 )
 
@@ -52,22 +53,29 @@ func main() {
 		Usage: "A tool for exporting data",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
-				Name:     "user",
-				Aliases:  []string{"u"},
-				Usage:    "Oracle `USER` name",
-				Required: true,
+				Name:    "dsn",
+				Usage:   "Oracle connection as DBI dsn, EZConnect, go-ora url or profile name",
+				EnvVars: []string{"ORACLE_DSN"},
 			},
 			&cli.StringFlag{
-				Name:     "pass",
-				Aliases:  []string{"p"},
-				Usage:    "Oracle `PASSWORD`",
-				Required: true,
+				Name:    "profile-file",
+				Usage:   "yaml file of named connection profiles",
+				EnvVars: []string{"DB_PROFILE_FILE"},
 			},
 			&cli.StringFlag{
-				Name:     "host",
-				Aliases:  []string{"H"},
-				Usage:    "Oracle `HOST`",
-				Required: true,
+				Name:    "user",
+				Aliases: []string{"u"},
+				Usage:   "Oracle `USER` name",
+			},
+			&cli.StringFlag{
+				Name:    "pass",
+				Aliases: []string{"p"},
+				Usage:   "Oracle `PASSWORD`",
+			},
+			&cli.StringFlag{
+				Name:    "host",
+				Aliases: []string{"H"},
+				Usage:   "Oracle `HOST`",
 			},
 			&cli.IntFlag{
 				Name:    "port",
@@ -219,16 +227,27 @@ func strainPhenoAction(cltx *cli.Context) error {
 	return nil
 }
 
+// connectionProfile builds the connection from the dsn or the profile
+// name, the host, port, sid, user and pass flags take precedence
+func connectionProfile(cltx *cli.Context) (*dbprofile.Profile, error) {
+	prof := dbprofile.Profile{
+		Host:     cltx.String("host"),
+		SID:      cltx.String("sid"),
+		User:     cltx.String("user"),
+		Password: cltx.String("pass"),
+	}
+	if cltx.IsSet("port") {
+		prof.Port = cltx.Int("port")
+	}
+	return dbprofile.FromValues(prof, cltx.String("dsn"), cltx.String("profile-file"))
+}
+
 func setupDatabaseConnection(cltx *cli.Context) (*sql.DB, error) {
-	connStr := go_ora.BuildUrl(
-		cltx.String("host"),
-		cltx.Int("port"),
-		cltx.String("sid"),
-		cltx.String("user"),
-		cltx.String("pass"),
-		nil,
-	)
-	return sql.Open("oracle", connStr)
+	prof, err := connectionProfile(cltx)
+	if err != nil {
+		return nil, err
+	}
+	return sql.Open("oracle", prof.URL())
 }
 
 func writeCSVHeader(csvWriter *csv.Writer, header []string) error {
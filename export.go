@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/urfave/cli"
 )
@@ -10,6 +13,10 @@ func main() {
 	app := cli.NewApp()
 	app.Name = "Command line wrapper to export data from Oracle chado using modware-loader"
 	app.Version = "1.0.0"
+	// cancelled on interrupt, stops all the running modware subprocesses
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	app.Metadata = map[string]interface{}{"context": ctx}
 	app.Flags = []cli.Flag{
 		cli.StringFlag{
 			Name:  "log-level",
@@ -26,6 +33,29 @@ func main() {
 			Usage: "hook names for sending log in addition to stderr",
 			Value: &cli.StringSlice{},
 		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "print the jobs that a command would run without running them",
+		},
+		cli.StringFlag{
+			Name:  "plan-format",
+			Usage: "format of the dry run output, either of text or json",
+			Value: "text",
+		},
+		cli.StringFlag{
+			Name:   "profile-file",
+			EnvVar: "DB_PROFILE_FILE",
+			Usage:  "yaml file of named connection profiles, a dsn could be given as the name of a profile",
+		},
+		cli.StringFlag{
+			Name:  "secrets-folder",
+			Usage: "folder of docker secrets where the credentials are looked up if not given",
+			Value: "/run/secrets",
+		},
+		cli.BoolFlag{
+			Name:  "keep-configs",
+			Usage: "keep the generated config files with the credentials after the run",
+		},
 		cli.StringFlag{
 			Name:   "slack-channel",
 			EnvVar: "SLACK_CHANNEL",
@@ -43,6 +73,30 @@ func main() {
 			Usage:  "Export the canonical gff3 of all the dictyostelids",
 			Action: CanonicalGFF3Action,
 			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "validate",
+					Usage: "validate the exported gff3 files after all the exports are completed",
+				},
+				cli.BoolFlag{
+					Name:  "resume",
+					Usage: "skip the jobs that completed in an earlier run and whose output is unchanged",
+				},
+				cli.BoolFlag{
+					Name:  "tee-output",
+					Usage: "also write the output of every export job to a file in the log folder",
+				},
+				cli.DurationFlag{
+					Name:  "job-timeout",
+					Usage: "maximum running time of every export job(e.g. 90m), no limit if not given",
+				},
+				cli.IntFlag{
+					Name:  "parallel",
+					Usage: "maximum number of export jobs running at the same time, 0 runs all of them together",
+				},
+				cli.StringFlag{
+					Name:  "manifest, m",
+					Usage: "yaml or json manifest of the export jobs, the builtin one is used if not given",
+				},
 				cli.StringFlag{
 					Name:  "output-folder, of",
 					Usage: "Output folder",
@@ -90,6 +144,30 @@ func main() {
 			Usage:  "Export the additional gff3 of all the D.discoideum",
 			Action: ExtraGFF3Action,
 			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "validate",
+					Usage: "validate the exported gff3 files after all the exports are completed",
+				},
+				cli.BoolFlag{
+					Name:  "resume",
+					Usage: "skip the jobs that completed in an earlier run and whose output is unchanged",
+				},
+				cli.BoolFlag{
+					Name:  "tee-output",
+					Usage: "also write the output of every export job to a file in the log folder",
+				},
+				cli.DurationFlag{
+					Name:  "job-timeout",
+					Usage: "maximum running time of every export job(e.g. 90m), no limit if not given",
+				},
+				cli.IntFlag{
+					Name:  "parallel",
+					Usage: "maximum number of export jobs running at the same time, 0 runs all of them together",
+				},
+				cli.StringFlag{
+					Name:  "manifest, m",
+					Usage: "yaml or json manifest of the export jobs, the builtin one is used if not given",
+				},
 				cli.StringFlag{
 					Name:  "output-folder, of",
 					Usage: "Output folder",
@@ -122,11 +200,68 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "validate-manifest",
+			Usage:  "Validate a manifest of export jobs, validates the builtin ones if no manifest is given",
+			Action: ValidateManifestAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "manifest, m",
+					Usage: "yaml or json manifest of the export jobs",
+				},
+			},
+		},
+		{
+			Name:   "verify-manifest",
+			Usage:  "Verify the files of an output folder against the manifests of its commands",
+			Action: VerifyManifestAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output-folder, of",
+					Usage: "Output folder of an export command [required]",
+				},
+				cli.StringFlag{
+					Name:  "command",
+					Usage: "Only verify the manifest of this command, all of them are verified by default",
+				},
+			},
+		},
+		{
+			Name:   "status",
+			Usage:  "Print the state of the export jobs that were run with a config folder",
+			Action: StatusAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "config-folder, cf",
+					Usage: "Config folder of an export command, output folder for commands without one [required]",
+				},
+				cli.StringFlag{
+					Name:  "command",
+					Usage: "Only print the state of this command, all of them are printed by default",
+				},
+			},
+		},
 		{
 			Name:   "geneannotation",
 			Usage:  "Export annotations associated with gene models",
 			Action: GeneAnnoAction,
 			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "resume",
+					Usage: "skip the jobs that completed in an earlier run and whose output is unchanged",
+				},
+				cli.BoolFlag{
+					Name:  "tee-output",
+					Usage: "also write the output of every export job to a file in the log folder",
+				},
+				cli.DurationFlag{
+					Name:  "job-timeout",
+					Usage: "maximum running time of every export job(e.g. 90m), no limit if not given",
+				},
+				cli.IntFlag{
+					Name:  "parallel",
+					Usage: "maximum number of export jobs running at the same time, 0 runs all of them together",
+				},
 				cli.StringFlag{
 					Name:  "output-folder, of",
 					Usage: "Output folder",
@@ -181,12 +316,89 @@ func main() {
 			Action:   DscOrderAction,
 			Before:   validateDscUsers,
 			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "format, f",
+					Usage: "Output format, legacy(csv without header), csv, jsonl or normalized(orders and order_items csv)",
+					Value: "legacy",
+				},
+				cli.StringFlag{
+					Name:  "unresolved",
+					Usage: "what to do with the plasmid names, strains and unknown items without a single stock id, keep, skip or fail, they are listed in stock_orders_unresolved.csv, the legacy format is not resolved",
+					Value: "keep",
+				},
+				cli.StringFlag{
+					Name:  "since",
+					Usage: "only export the rows modified since a timestamp(2006-01-02 15:04:05 or 2006-01-02) or the last successful run(last), keys of the removed rows go to a tombstones file",
+				},
+				cli.BoolFlag{
+					Name:  "resume",
+					Usage: "skip the jobs that completed in an earlier run and whose output is unchanged",
+				},
+				cli.StringFlag{
+					Name:        "output-folder, of",
+					Usage:       "Output folder",
+					Destination: &outfolder,
+					Value:       "/data/stockcenter",
+				},
+				cli.StringFlag{
+					Name:   "dsn",
+					Usage:  "connection of the oracle database in place of host, port and sid",
+					EnvVar: "ORACLE_DSN",
+				},
+				cli.StringFlag{
+					Name:   "host",
+					Usage:  "oracle database host name[required]",
+					EnvVar: "ORACLE_HOST",
+				},
+				cli.StringFlag{
+					Name:   "port",
+					Usage:  "oracle database port[required]",
+					EnvVar: "ORACLE_PORT",
+				},
+				cli.StringFlag{
+					Name:   "sid",
+					Usage:  "oracle database sid[required]",
+					EnvVar: "ORACLE_SID",
+				},
+				cli.StringFlag{
+					Name:   "user, u",
+					Usage:  "User name for oracle database [required]",
+					EnvVar: "ORACLE_USER",
+				},
+				cli.StringFlag{
+					Name:   "password, p",
+					Usage:  "Password for oracle database[required]",
+					EnvVar: "ORACLE_PASS",
+				},
+			},
+		},
+		{
+			Name:     "dsc-inventory",
+			Usage:    "Export the inventory of the stock center strains and plasmids",
+			Category: "dsc",
+			Action:   DscInventoryAction,
+			Before:   validateDscUsers,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "format, f",
+					Usage: "Output format, csv(with header) or jsonl",
+					Value: "csv",
+				},
+				cli.BoolFlag{
+					Name:  "resume",
+					Usage: "skip the jobs that completed in an earlier run and whose output is unchanged",
+				},
 				cli.StringFlag{
 					Name:        "output-folder, of",
 					Usage:       "Output folder",
 					Destination: &outfolder,
 					Value:       "/data/stockcenter",
 				},
+				cli.StringFlag{
+					Name:   "dsn",
+					Usage:  "connection of the oracle database in place of host, port and sid",
+					EnvVar: "ORACLE_DSN",
+				},
 				cli.StringFlag{
 					Name:   "host",
 					Usage:  "oracle database host name[required]",
@@ -214,6 +426,45 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:     "dsc-verify",
+			Usage:    "Check that the stock center exports refer to each other",
+			Category: "dsc",
+			Action:   DscVerifyAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output-folder, of",
+					Usage: "Folder with the catalog, orders and user annotations",
+					Value: "/data/stockcenter",
+				},
+				cli.StringFlag{
+					Name:  "orders-format",
+					Usage: "Format of the orders export, legacy, csv, jsonl or normalized",
+					Value: "legacy",
+				},
+				cli.StringFlag{
+					Name:  "strain-catalog",
+					Usage: "strain file of the catalog written by dsc-annotations, a row per strain with its id first",
+				},
+				cli.StringFlag{
+					Name:  "plasmid-catalog",
+					Usage: "plasmid file of the catalog written by dsc-annotations, a row per plasmid with its id and name first",
+				},
+				cli.StringFlag{
+					Name:  "users-file",
+					Usage: "users file of the colleagues export",
+					Value: "/data/users/users.csv",
+				},
+				cli.StringFlag{
+					Name:  "user-column",
+					Usage: "header of the users file column that holds the oracle login(e.g. CGM_DDB_STEF) found in created_by of the user annotations, the users are not checked if it is not given",
+				},
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "file of the json report, written to standard output if not given",
+				},
+			},
+		},
 		{
 			Name:     "dsc-annotations",
 			Usage:    "Export annotations",
@@ -221,6 +472,22 @@ func main() {
 			Before:   validateDsc,
 			Action:   StockCenterAction,
 			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "resume",
+					Usage: "skip the jobs that completed in an earlier run and whose output is unchanged",
+				},
+				cli.BoolFlag{
+					Name:  "tee-output",
+					Usage: "also write the output of every export job to a file in the log folder",
+				},
+				cli.DurationFlag{
+					Name:  "job-timeout",
+					Usage: "maximum running time of every export job(e.g. 90m), no limit if not given",
+				},
+				cli.IntFlag{
+					Name:  "parallel",
+					Usage: "maximum number of export jobs running at the same time, 0 runs all of them together",
+				},
 				cli.StringFlag{
 					Name:  "output-folder, of",
 					Usage: "Output folder",
@@ -275,12 +542,25 @@ func main() {
 			Action:   DscUsersAction,
 			Before:   validateDscUsers,
 			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "since",
+					Usage: "only export the rows modified since a timestamp(2006-01-02 15:04:05 or 2006-01-02) or the last successful run(last), keys of the removed rows go to a tombstones file",
+				},
+				cli.BoolFlag{
+					Name:  "resume",
+					Usage: "skip the jobs that completed in an earlier run and whose output is unchanged",
+				},
 				cli.StringFlag{
 					Name:        "output-folder, of",
 					Usage:       "Output folder of the data files",
 					Destination: &outfolder,
 					Value:       "/data/stockcenter",
 				},
+				cli.StringFlag{
+					Name:   "dsn",
+					Usage:  "connection of the oracle database in place of host, port and sid",
+					EnvVar: "ORACLE_DSN",
+				},
 				cli.StringFlag{
 					Name:   "host",
 					Usage:  "oracle database host name[required]",
@@ -314,6 +594,14 @@ func main() {
 			Action: ColleaguesAction,
 			Before: validateColleagues,
 			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "resume",
+					Usage: "skip the jobs that completed in an earlier run and whose output is unchanged",
+				},
+				cli.DurationFlag{
+					Name:  "job-timeout",
+					Usage: "maximum running time of every export job(e.g. 90m), no limit if not given",
+				},
 				cli.StringFlag{
 					Name:  "output-folder, of",
 					Usage: "Output folder",
@@ -341,6 +629,22 @@ func main() {
 			Usage:  "Export the literature and annotations",
 			Action: LiteratureAction,
 			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "resume",
+					Usage: "skip the jobs that completed in an earlier run and whose output is unchanged",
+				},
+				cli.BoolFlag{
+					Name:  "tee-output",
+					Usage: "also write the output of every export job to a file in the log folder",
+				},
+				cli.DurationFlag{
+					Name:  "job-timeout",
+					Usage: "maximum running time of every export job(e.g. 90m), no limit if not given",
+				},
+				cli.IntFlag{
+					Name:  "parallel",
+					Usage: "maximum number of export jobs running at the same time, 0 runs all of them together",
+				},
 				cli.StringFlag{
 					Name:  "output-folder, of",
 					Usage: "Output folder",
@@ -378,6 +682,178 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "validate-gff3",
+			Usage:  "Validate a gff3 file and report the problems with their line numbers",
+			Action: ValidateGFF3Action,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "input, i",
+					Usage: "Name of the input gff3 file, required",
+				},
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "Name of the report file, printed to stdout if not given",
+				},
+				cli.StringFlag{
+					Name:  "format, f",
+					Usage: "format of the report, either of json or csv",
+					Value: "json",
+				},
+			},
+		},
+		{
+			Name:   "gff3-rewrite",
+			Usage:  "Rewrite the attributes of a gff3 file with the rules of a yaml file",
+			Action: RewriteGFF3Action,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "input, i",
+					Usage: "Name of the input gff3 file, required",
+				},
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "Name of the output gff3 file, required",
+				},
+				cli.StringFlag{
+					Name:  "rules, r",
+					Usage: "yaml file with the rewrite rules, required",
+				},
+			},
+		},
+		{
+			Name:   "split-gff3",
+			Usage:  "Split the features of a gff3 file to several files by type, sequence ontology term or source",
+			Action: SplitGFF3Action,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "input, i",
+					Usage: "Name of the input gff3 file, required",
+				},
+				cli.StringSliceFlag{
+					Name:  "rule, r",
+					Usage: "Output and its matchers, for example ncrna.gff3=so:ncRNA,source:tRNAscan, at least one is required",
+				},
+				cli.StringFlag{
+					Name:  "default, d",
+					Usage: "Name of the gff3 file for the features that match no rule, required",
+				},
+				cli.StringFlag{
+					Name:  "fasta, f",
+					Usage: "Name of the fasta file for the sequences, by default they stay in the default output",
+				},
+				cli.StringFlag{
+					Name:  "so-file",
+					Usage: "Sequence ontology obo file, by default the builtin subset of terms is used",
+				},
+			},
+		},
+		{
+			Name:      "merge-gff3",
+			Usage:     "Merge gff3 files to a single file sorted by seqid and start",
+			ArgsUsage: "[input gff3 files]",
+			Action:    MergeGFF3Action,
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "input, i",
+					Usage: "Name of an input gff3 file, could be repeated or given as arguments",
+				},
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "Name of the merged gff3 file, required",
+				},
+				cli.IntFlag{
+					Name:  "chunk-size",
+					Usage: "Number of features that are sorted in memory",
+					Value: defaultChunkSize,
+				},
+				cli.StringFlag{
+					Name:  "temp-folder",
+					Usage: "Folder for the temporary sort files, by default the system temporary folder",
+				},
+			},
+		},
+		{
+			Name:   "convert-gff3",
+			Usage:  "Convert the transcripts of a gff3 file to gtf, bed or json lines",
+			Action: ConvertGFF3Action,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "input, i",
+					Usage: "Name of the input gff3 file, required",
+				},
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "Name of the output file, required",
+				},
+				cli.StringFlag{
+					Name:  "format, f",
+					Usage: "Output format, gtf, bed or jsonl",
+					Value: "gtf",
+				},
+			},
+		},
+		{
+			Name:   "extract-sequences",
+			Usage:  "Write the genomic, transcript, cds and protein sequences of a gff3 file with a FASTA section",
+			Action: ExtractSequencesAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "input, i",
+					Usage: "Name of the input gff3 file, required",
+				},
+				cli.StringFlag{
+					Name:  "output-folder, of",
+					Usage: "Folder of the fasta files, by default the folder of the input",
+				},
+				cli.StringFlag{
+					Name:  "prefix, p",
+					Usage: "Prefix of the fasta files, by default the name of the input up to the first dot",
+				},
+				cli.BoolFlag{
+					Name:  "strict",
+					Usage: "Fail if a translation does not agree with its polypeptide feature",
+				},
+			},
+		},
+		{
+			Name:      "gff3-stats",
+			Usage:     "Count the features of gff3 files by seqid, type and source",
+			ArgsUsage: "[gff3 files or folders]",
+			Action:    GFF3StatsAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "Name of the output file, by default the stats are written to stdout",
+				},
+				cli.StringFlag{
+					Name:  "format, f",
+					Usage: "Output format, text or json",
+					Value: "text",
+				},
+			},
+		},
+		{
+			Name:      "gff3-diff",
+			Usage:     "Compare the features of two gff3 exports by ID",
+			ArgsUsage: "[old file or folder] [new file or folder]",
+			Action:    GFF3DiffAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "Name of the output file, by default the diff is written to stdout",
+				},
+				cli.StringFlag{
+					Name:  "format, f",
+					Usage: "Output format, text or json",
+					Value: "text",
+				},
+				cli.BoolFlag{
+					Name:  "exit-code",
+					Usage: "Exit with status 1 if the exports differ",
+				},
+			},
+		},
 		{
 			Name:  "clean-dbxref",
 			Usage: "Remove dbxref attribute(s) from gff3 file",
@@ -409,5 +885,11 @@ func main() {
 			Action: SplitPolypeptideAction,
 		},
 	}
+	for i, cmd := range app.Commands {
+		app.Commands[i].Before = withCredentials(cmd.Before)
+		if action, ok := cmd.Action.(func(*cli.Context) error); ok {
+			app.Commands[i].Action = withRedactedErrors(action)
+		}
+	}
 	app.Run(os.Args)
 }
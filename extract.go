@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/migration-data-export/gff3"
+	"github.com/urfave/cli"
+)
+
+// SequenceOutputs are the fasta files of extract-sequences, a nil
+// writer is skipped
+type SequenceOutputs struct {
+	Genomic     io.Writer
+	Transcripts io.Writer
+	CDS         io.Writer
+	Proteins    io.Writer
+}
+
+// ExtractStats counts the written sequences and keeps the problems found
+// while comparing the translations with the polypeptide features
+type ExtractStats struct {
+	Genomic     int
+	Transcripts int
+	CDS         int
+	Proteins    int
+	Problems    []string
+}
+
+func (st *ExtractStats) problem(format string, args ...interface{}) {
+	st.Problems = append(st.Problems, fmt.Sprintf(format, args...))
+}
+
+func fastaWriter(w io.Writer) *gff3.Writer {
+	if w == nil {
+		return nil
+	}
+	return gff3.NewFastaWriter(w)
+}
+
+// subSequence returns the residues from start to end, both one based
+func subSequence(seq *gff3.Sequence, start, end int) (string, error) {
+	if start < 1 || end > len(seq.Residues) || start > end {
+		return "", fmt.Errorf("range %d..%d is outside of %s of length %d", start, end, seq.ID, len(seq.Residues))
+	}
+	return seq.Residues[start-1 : end], nil
+}
+
+// spliceSequence joins the residues of the segments on the strand
+func spliceSequence(seq *gff3.Sequence, parts []*gff3.Feature, strand string) (string, error) {
+	var b strings.Builder
+	for _, p := range transcriptOrder(parts, "+") {
+		s, err := subSequence(seq, p.Start, p.End)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s)
+	}
+	if strand == "-" {
+		return gff3.ReverseComplement(b.String()), nil
+	}
+	return b.String(), nil
+}
+
+// sequenceHeader is the description of a transcript sequence, the ID
+// of the transcript is the first word
+func sequenceHeader(g *gff3.Gene, t *gff3.Transcript, start, end int) string {
+	h := []string{"gene=" + g.Feature.ID()}
+	if name, ok := g.Feature.Attributes.First("Name"); ok {
+		h = append(h, "name="+name)
+	}
+	h = append(h,
+		"type="+t.Feature.Type,
+		fmt.Sprintf("location=%s:%d..%d(%s)", t.Feature.SeqID, start, end, t.Feature.Strand),
+	)
+	return strings.Join(h, " ")
+}
+
+// ExtractSequences writes the sequences of the FASTA section, the spliced
+// transcripts, their coding sequences and translations. The translations
+// are checked against the polypeptide features that derive from the
+// transcripts.
+func ExtractSequences(in io.Reader, out SequenceOutputs) (*ExtractStats, error) {
+	var features []*gff3.Feature
+	sequences := make(map[string]*gff3.Sequence)
+	var order []string
+	err := transformGFF3(in, func(rec *gff3.Record) error {
+		switch rec.Kind {
+		case gff3.FeatureKind:
+			features = append(features, rec.Feature)
+		case gff3.SequenceKind:
+			if _, ok := sequences[rec.Sequence.ID]; !ok {
+				order = append(order, rec.Sequence.ID)
+			}
+			sequences[rec.Sequence.ID] = rec.Sequence
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	polypeptides := make(map[string]*gff3.Feature)
+	for _, f := range features {
+		if isPolyPeptide(f) {
+			for _, id := range f.Attributes.Get("Derives_from") {
+				polypeptides[id] = f
+			}
+		}
+	}
+	gw, tw, cw, pw := fastaWriter(out.Genomic), fastaWriter(out.Transcripts),
+		fastaWriter(out.CDS), fastaWriter(out.Proteins)
+	write := func(fw *gff3.Writer, s *gff3.Sequence, count *int) error {
+		if fw == nil {
+			return nil
+		}
+		*count++
+		return fw.WriteSequence(s)
+	}
+	st := new(ExtractStats)
+	for _, id := range order {
+		if err := write(gw, sequences[id], &st.Genomic); err != nil {
+			return nil, err
+		}
+	}
+	for _, g := range gff3.NewIndex(features).Genes(gff3.DefaultOntology()) {
+		for _, t := range g.Transcripts {
+			id := t.Feature.ID()
+			seq, ok := sequences[t.Feature.SeqID]
+			if !ok {
+				st.problem("%s: sequence %s is missing", id, t.Feature.SeqID)
+				continue
+			}
+			strand := t.Feature.Strand
+			residues, err := spliceSequence(seq, t.Exons, strand)
+			if err != nil {
+				st.problem("%s: %s", id, err)
+				continue
+			}
+			start, end := t.Exons[0].Start, t.Exons[len(t.Exons)-1].End
+			err = write(tw, &gff3.Sequence{
+				ID:          id,
+				Description: sequenceHeader(g, t, start, end),
+				Residues:    residues,
+			}, &st.Transcripts)
+			if err != nil {
+				return nil, err
+			}
+			if len(t.CDS) == 0 {
+				continue
+			}
+			cds, err := spliceSequence(seq, t.CDS, strand)
+			if err != nil {
+				st.problem("%s: %s", id, err)
+				continue
+			}
+			if phase := phaseOf(transcriptOrder(t.CDS, strand)[0]); phase < len(cds) {
+				cds = cds[phase:]
+			}
+			cs, ce, _ := t.CodingRange()
+			err = write(cw, &gff3.Sequence{
+				ID:          id,
+				Description: sequenceHeader(g, t, cs, ce),
+				Residues:    cds,
+			}, &st.CDS)
+			if err != nil {
+				return nil, err
+			}
+			protein := gff3.Translate(cds)
+			desc := []string{"gene=" + g.Feature.ID()}
+			if poly, ok := polypeptides[id]; ok {
+				desc = append(desc, "polypeptide="+poly.ID())
+				if poly.Start != cs || poly.End != ce {
+					st.problem(
+						"%s: polypeptide %s spans %d..%d but the coding range is %d..%d",
+						id, poly.ID(), poly.Start, poly.End, cs, ce,
+					)
+				}
+			}
+			if len(cds)%3 != 0 {
+				st.problem("%s: coding length %d is not a multiple of three", id, len(cds))
+			}
+			protein = strings.TrimSuffix(protein, "*")
+			if i := strings.Index(protein, "*"); i >= 0 {
+				st.problem("%s: internal stop codon at residue %d", id, i+1)
+			}
+			desc = append(desc, fmt.Sprintf("length=%d", len(protein)))
+			err = write(pw, &gff3.Sequence{
+				ID:          id,
+				Description: strings.Join(desc, " "),
+				Residues:    protein,
+			}, &st.Proteins)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, fw := range []*gff3.Writer{gw, tw, cw, pw} {
+		if fw == nil {
+			continue
+		}
+		if err := fw.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+func ExtractSequencesAction(c *cli.Context) error {
+	if !c.IsSet("input") {
+		return cli.NewExitError("argument input is required", 2)
+	}
+	prefix := c.String("prefix")
+	if len(prefix) == 0 {
+		prefix = strings.Split(filepath.Base(c.String("input")), ".")[0]
+	}
+	folder := c.String("output-folder")
+	if len(folder) == 0 {
+		folder = filepath.Dir(c.String("input"))
+	}
+	if err := CreateFolder(folder); err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	in, err := os.Open(c.String("input"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("error in opening file %s", err), 2)
+	}
+	defer in.Close()
+	var out SequenceOutputs
+	for _, o := range []struct {
+		kind string
+		w    *io.Writer
+	}{
+		{"genomic", &out.Genomic},
+		{"transcripts", &out.Transcripts},
+		{"cds", &out.CDS},
+		{"proteins", &out.Proteins},
+	} {
+		f, err := os.Create(filepath.Join(folder, fmt.Sprintf("%s_%s.fa", prefix, o.kind)))
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("error in writing file %s", err), 2)
+		}
+		defer f.Close()
+		*o.w = f
+	}
+	st, err := ExtractSequences(in, out)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("error in extracting sequences of %s %s", c.String("input"), err),
+			2,
+		)
+	}
+	log := getLogger(c)
+	for _, p := range st.Problems {
+		log.Warn(p)
+	}
+	log.Infof(
+		"extracted %d genomic, %d transcript, %d cds and %d protein sequences of %s",
+		st.Genomic, st.Transcripts, st.CDS, st.Proteins, c.String("input"),
+	)
+	if c.Bool("strict") && len(st.Problems) > 0 {
+		return cli.NewExitError(fmt.Sprintf("%d problems found in %s", len(st.Problems), c.String("input")), 2)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractSequences(t *testing.T) {
+	in, err := os.Open(filepath.Join("testdata", "gff3", "extract.gff3"))
+	require.NoError(t, err)
+	defer in.Close()
+	var genomic, transcripts, cds, proteins bytes.Buffer
+	st, err := ExtractSequences(in, SequenceOutputs{
+		Genomic:     &genomic,
+		Transcripts: &transcripts,
+		CDS:         &cds,
+		Proteins:    &proteins,
+	})
+	require.NoError(t, err)
+	assert := assert.New(t)
+	assert.Equal(readFixture(t, "extract_genomic.fa"), genomic.String())
+	assert.Equal(readFixture(t, "extract_transcripts.fa"), transcripts.String())
+	assert.Equal(readFixture(t, "extract_cds.fa"), cds.String())
+	assert.Equal(readFixture(t, "extract_proteins.fa"), proteins.String())
+	assert.Equal([]int{1, 3, 2, 2}, []int{st.Genomic, st.Transcripts, st.CDS, st.Proteins})
+	assert.Equal([]string{
+		"DDB0216438: polypeptide DDB0216438_poly spans 41..57 but the coding range is 40..57",
+		"DDB0220132: sequence chr2 is missing",
+	}, st.Problems)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fixtureTable is the result of a query of the fixture database
+type fixtureTable struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// fixtureDB is a database/sql driver serving fixed results by query text,
+// it counts the queries and the cursors that are open at the same time
+type fixtureDB struct {
+	mu      sync.Mutex
+	tables  map[string]func(args []driver.Value) (*fixtureTable, error)
+	latency time.Duration
+	queries int
+	open    int
+	maxOpen int
+}
+
+func newFixtureDB() *fixtureDB {
+	return &fixtureDB{tables: make(map[string]func([]driver.Value) (*fixtureTable, error))}
+}
+
+// table serves the same rows for every run of the query
+func (fdb *fixtureDB) table(query string, columns []string, rows [][]driver.Value) {
+	fdb.tables[query] = func([]driver.Value) (*fixtureTable, error) {
+		return &fixtureTable{columns: columns, rows: rows}, nil
+	}
+}
+
+var (
+	fixtureMu  sync.Mutex
+	fixtureDBs = make(map[string]*fixtureDB)
+)
+
+func init() {
+	sql.Register("fixture", fixtureDriver{})
+}
+
+// openFixtureDB opens a handle to the fixture database
+func openFixtureDB(tb testing.TB, fdb *fixtureDB) *sql.DB {
+	fixtureMu.Lock()
+	name := fmt.Sprintf("%s-%d", tb.Name(), len(fixtureDBs))
+	fixtureDBs[name] = fdb
+	fixtureMu.Unlock()
+	dbh, err := sql.Open("fixture", name)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { dbh.Close() })
+	return dbh
+}
+
+type fixtureDriver struct{}
+
+func (fixtureDriver) Open(name string) (driver.Conn, error) {
+	fixtureMu.Lock()
+	defer fixtureMu.Unlock()
+	fdb, ok := fixtureDBs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown fixture database %s", name)
+	}
+	return &fixtureConn{fdb}, nil
+}
+
+type fixtureConn struct {
+	fdb *fixtureDB
+}
+
+func (c *fixtureConn) Prepare(query string) (driver.Stmt, error) {
+	fn, ok := c.fdb.tables[query]
+	if !ok {
+		return nil, fmt.Errorf("no fixture for query %s", query)
+	}
+	return &fixtureStmt{fdb: c.fdb, fn: fn}, nil
+}
+
+func (c *fixtureConn) Close() error {
+	return nil
+}
+
+func (c *fixtureConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fixtureStmt struct {
+	fdb *fixtureDB
+	fn  func([]driver.Value) (*fixtureTable, error)
+}
+
+func (s *fixtureStmt) Close() error {
+	return nil
+}
+
+func (s *fixtureStmt) NumInput() int {
+	return -1
+}
+
+func (s *fixtureStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("exec is not supported")
+}
+
+func (s *fixtureStmt) Query(args []driver.Value) (driver.Rows, error) {
+	// every query pays the round trip to the database
+	time.Sleep(s.fdb.latency)
+	t, err := s.fn(args)
+	if err != nil {
+		return nil, err
+	}
+	s.fdb.mu.Lock()
+	defer s.fdb.mu.Unlock()
+	s.fdb.queries++
+	s.fdb.open++
+	if s.fdb.open > s.fdb.maxOpen {
+		s.fdb.maxOpen = s.fdb.open
+	}
+	return &fixtureRows{fdb: s.fdb, table: t}, nil
+}
+
+type fixtureRows struct {
+	fdb    *fixtureDB
+	table  *fixtureTable
+	next   int
+	closed bool
+}
+
+func (r *fixtureRows) Columns() []string {
+	return r.table.columns
+}
+
+func (r *fixtureRows) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.fdb.mu.Lock()
+	r.fdb.open--
+	r.fdb.mu.Unlock()
+	return nil
+}
+
+func (r *fixtureRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.table.rows) {
+		return io.EOF
+	}
+	copy(dest, r.table.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,236 @@
+package gff3
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// DiffFeature identifies a feature of the diff by its key, the ID of the
+// feature or its parents, type and position among its siblings of the
+// same type for features without an ID
+type DiffFeature struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"`
+	SeqID string `json:"seqid"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// FieldChange is a column or attribute that differs
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// FeatureChange is a feature that is in both files with its differences
+type FeatureChange struct {
+	DiffFeature
+	Changes []FieldChange `json:"changes"`
+}
+
+// Diff is the difference of the features of two files
+type Diff struct {
+	Old       string          `json:"old"`
+	New       string          `json:"new"`
+	Added     []DiffFeature   `json:"added"`
+	Removed   []DiffFeature   `json:"removed"`
+	Changed   []FeatureChange `json:"changed"`
+	Unchanged int             `json:"unchanged"`
+}
+
+// keyedFeatures are the lines of the features by key in the order
+// of the file
+type keyedFeatures struct {
+	keys  []string
+	lines map[string][]*Feature
+}
+
+func readKeyedFeatures(in io.Reader) (*keyedFeatures, error) {
+	kf := &keyedFeatures{lines: make(map[string][]*Feature)}
+	siblings := make(map[string]int)
+	r := NewReader(in)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return kf, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rec.Kind != FeatureKind {
+			continue
+		}
+		f := rec.Feature
+		key := f.ID()
+		if len(key) == 0 {
+			prefix := strings.Join(f.Parents(), ",") + "/" + f.Type
+			siblings[prefix]++
+			key = fmt.Sprintf("%s/%d", prefix, siblings[prefix])
+		}
+		if _, ok := kf.lines[key]; !ok {
+			kf.keys = append(kf.keys, key)
+		}
+		kf.lines[key] = append(kf.lines[key], f)
+	}
+}
+
+func diffFeature(key string, f *Feature) DiffFeature {
+	return DiffFeature{Key: key, Type: f.Type, SeqID: f.SeqID, Start: f.Start, End: f.End}
+}
+
+// compareLines lists the differences of the lines of a feature, the
+// lines are compared in the order of their start
+func compareLines(old, new []*Feature) []FieldChange {
+	var changes []FieldChange
+	if len(old) != len(new) {
+		changes = append(changes, FieldChange{
+			Field: "lines",
+			Old:   strconv.Itoa(len(old)),
+			New:   strconv.Itoa(len(new)),
+		})
+	}
+	o := append([]*Feature{}, old...)
+	n := append([]*Feature{}, new...)
+	sortByStart(o)
+	sortByStart(n)
+	for i := 0; i < len(o) && i < len(n); i++ {
+		suffix := ""
+		if len(o) > 1 || len(n) > 1 {
+			suffix = fmt.Sprintf("[%d]", i+1)
+		}
+		for _, c := range compareFeatures(o[i], n[i]) {
+			c.Field += suffix
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+func compareFeatures(o, n *Feature) []FieldChange {
+	var changes []FieldChange
+	add := func(field, old, new string) {
+		if old != new {
+			changes = append(changes, FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	add("seqid", o.SeqID, n.SeqID)
+	add("source", o.Source, n.Source)
+	add("type", o.Type, n.Type)
+	add("start", strconv.Itoa(o.Start), strconv.Itoa(n.Start))
+	add("end", strconv.Itoa(o.End), strconv.Itoa(n.End))
+	add("score", o.Score, n.Score)
+	add("strand", o.Strand, n.Strand)
+	add("phase", o.Phase, n.Phase)
+	add("parents", strings.Join(o.Parents(), ","), strings.Join(n.Parents(), ","))
+	var tags []string
+	seen := map[string]bool{"ID": true, "Parent": true}
+	for _, a := range append(append(Attributes{}, o.Attributes...), n.Attributes...) {
+		if !seen[a.Tag] {
+			seen[a.Tag] = true
+			tags = append(tags, a.Tag)
+		}
+	}
+	sort.Strings(tags)
+	for _, t := range tags {
+		add("attribute "+t, strings.Join(o.Attributes.Get(t), ","), strings.Join(n.Attributes.Get(t), ","))
+	}
+	return changes
+}
+
+// DiffFeatures compares the features of two files by their keys
+func DiffFeatures(old, new io.Reader) (*Diff, error) {
+	of, err := readKeyedFeatures(old)
+	if err != nil {
+		return nil, fmt.Errorf("error in reading old file %s", err)
+	}
+	nf, err := readKeyedFeatures(new)
+	if err != nil {
+		return nil, fmt.Errorf("error in reading new file %s", err)
+	}
+	d := &Diff{
+		Added:   make([]DiffFeature, 0),
+		Removed: make([]DiffFeature, 0),
+		Changed: make([]FeatureChange, 0),
+	}
+	for _, key := range of.keys {
+		if _, ok := nf.lines[key]; !ok {
+			d.Removed = append(d.Removed, diffFeature(key, of.lines[key][0]))
+		}
+	}
+	for _, key := range nf.keys {
+		lines := nf.lines[key]
+		old, ok := of.lines[key]
+		if !ok {
+			d.Added = append(d.Added, diffFeature(key, lines[0]))
+			continue
+		}
+		changes := compareLines(old, lines)
+		if len(changes) == 0 {
+			d.Unchanged++
+			continue
+		}
+		d.Changed = append(d.Changed, FeatureChange{
+			DiffFeature: diffFeature(key, lines[0]),
+			Changes:     changes,
+		})
+	}
+	return d, nil
+}
+
+// Empty tells if the files have the same features
+func (d *Diff) Empty() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Changed) == 0
+}
+
+// WriteJSON writes the diff as indented json
+func (d *Diff) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// WriteText writes a summary followed by the added(+), removed(-) and
+// changed(~) features
+func (d *Diff) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "old\t%s\n", d.Old)
+	fmt.Fprintf(tw, "new\t%s\n", d.New)
+	fmt.Fprintf(tw, "added\t%d\n", len(d.Added))
+	fmt.Fprintf(tw, "removed\t%d\n", len(d.Removed))
+	fmt.Fprintf(tw, "changed\t%d\n", len(d.Changed))
+	fmt.Fprintf(tw, "unchanged\t%d\n", d.Unchanged)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	line := func(mark string, f DiffFeature) error {
+		_, err := fmt.Fprintf(w, "%s %s %s %s:%d..%d\n", mark, f.Key, f.Type, f.SeqID, f.Start, f.End)
+		return err
+	}
+	for _, f := range d.Added {
+		if err := line("+", f); err != nil {
+			return err
+		}
+	}
+	for _, f := range d.Removed {
+		if err := line("-", f); err != nil {
+			return err
+		}
+	}
+	for _, f := range d.Changed {
+		if err := line("~", f.DiffFeature); err != nil {
+			return err
+		}
+		for _, c := range f.Changes {
+			if _, err := fmt.Fprintf(w, "    %s: %q -> %q\n", c.Field, c.Old, c.New); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package gff3
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const diffOld = `##gff-version 3
+chr1	dictyBase	gene	10	200	.	+	.	ID=g1;Name=abcA
+chr1	dictyBase	mRNA	10	200	.	+	.	ID=m1;Parent=g1
+chr1	dictyBase	exon	10	90	.	+	.	Parent=m1
+chr1	dictyBase	exon	120	200	.	+	.	Parent=m1
+chr1	dictyBase	gene	300	400	.	-	.	ID=g2
+chr2	dictyBase	CDS	10	30	.	+	0	ID=cds1;Parent=m3
+chr2	dictyBase	CDS	50	70	.	+	0	ID=cds1;Parent=m3
+`
+
+const diffNew = `##gff-version 3
+chr1	dictyBase	gene	10	210	.	+	.	ID=g1;Name=abcB;Note=renamed
+chr1	dictyBase	mRNA	10	210	.	+	.	ID=m1;Parent=g1
+chr1	dictyBase	exon	10	90	.	+	.	Parent=m1
+chr1	dictyBase	exon	120	210	.	+	.	Parent=m1
+chr1	GenBank	match	500	600	.	+	.	ID=aln1
+chr2	dictyBase	CDS	50	70	.	+	0	ID=cds1;Parent=m3
+chr2	dictyBase	CDS	10	30	.	+	0	ID=cds1;Parent=m3
+`
+
+func TestCountFeatures(t *testing.T) {
+	st, err := CountFeatures(strings.NewReader(diffOld + "chr1\tbad\n##FASTA\n>chr1\nACGT\n"))
+	require.NoError(t, err)
+	assert := assert.New(t)
+	assert.Equal(7, st.Features)
+	assert.Equal(1, st.Sequences)
+	assert.Equal(1, st.Invalid)
+	assert.Equal(map[string]int{"chr1": 5, "chr2": 2}, st.SeqIDs)
+	assert.Equal(map[string]int{"gene": 2, "mRNA": 1, "exon": 2, "CDS": 2}, st.Types)
+	assert.Equal(map[string]int{"dictyBase": 7}, st.Sources)
+	assert.Equal([]StatsRow{
+		{"chr1", "exon", "dictyBase", 2},
+		{"chr1", "gene", "dictyBase", 2},
+		{"chr1", "mRNA", "dictyBase", 1},
+		{"chr2", "CDS", "dictyBase", 2},
+	}, st.Rows)
+	var out bytes.Buffer
+	require.NoError(t, st.WriteText(&out))
+	assert.Contains(out.String(), "chr2   CDS   dictyBase  2\n")
+}
+
+func TestDiffFeatures(t *testing.T) {
+	d, err := DiffFeatures(strings.NewReader(diffOld), strings.NewReader(diffNew))
+	require.NoError(t, err)
+	assert := assert.New(t)
+	assert.Equal([]DiffFeature{{Key: "aln1", Type: "match", SeqID: "chr1", Start: 500, End: 600}}, d.Added)
+	assert.Equal([]DiffFeature{{Key: "g2", Type: "gene", SeqID: "chr1", Start: 300, End: 400}}, d.Removed)
+	require.Len(t, d.Changed, 3)
+	assert.Equal("g1", d.Changed[0].Key)
+	assert.Equal([]FieldChange{
+		{Field: "end", Old: "200", New: "210"},
+		{Field: "attribute Name", Old: "abcA", New: "abcB"},
+		{Field: "attribute Note", Old: "", New: "renamed"},
+	}, d.Changed[0].Changes)
+	assert.Equal("m1", d.Changed[1].Key)
+	assert.Equal("m1/exon/2", d.Changed[2].Key, "should key the exons by parent and position")
+	assert.Equal([]FieldChange{{Field: "end", Old: "200", New: "210"}}, d.Changed[2].Changes)
+	assert.Equal(2, d.Unchanged, "should compare the lines of cds1 by start")
+	assert.False(d.Empty())
+	var out bytes.Buffer
+	require.NoError(t, d.WriteText(&out))
+	assert.Contains(out.String(), "+ aln1 match chr1:500..600\n- g2 gene chr1:300..400\n~ g1 gene chr1:10..210\n    end: \"200\" -> \"210\"\n")
+
+	d, err = DiffFeatures(strings.NewReader(diffOld), strings.NewReader(diffOld))
+	require.NoError(t, err)
+	assert.True(d.Empty())
+
+	f, err := os.Open("testdata/invalid.gff3")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = DiffFeatures(strings.NewReader(diffOld), f)
+	assert.Error(err)
+}
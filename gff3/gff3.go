@@ -0,0 +1,305 @@
+// Package gff3 reads and writes GFF3 files line by line. It understands
+// directives, comments, the trailing FASTA section, percent encoding and
+// multi valued attributes, the Index type links features to their parents
+// and children.
+package gff3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Kind is the type of a record
+type Kind int
+
+const (
+	FeatureKind Kind = iota + 1
+	DirectiveKind
+	CommentKind
+	SequenceKind
+)
+
+// Record is a single entry of a GFF3 file, only the field of its kind is set
+type Record struct {
+	Kind      Kind
+	Line      int
+	Feature   *Feature
+	Directive *Directive
+	Comment   string
+	Sequence  *Sequence
+}
+
+// Directive is a ## line, the ### line that closes all the open features
+// has the name #
+type Directive struct {
+	Name  string
+	Value string
+}
+
+func (d *Directive) String() string {
+	if len(d.Value) == 0 {
+		return "##" + d.Name
+	}
+	return fmt.Sprintf("##%s %s", d.Name, d.Value)
+}
+
+// SequenceRegion parses the value of a sequence-region directive
+func (d *Directive) SequenceRegion() (string, int, int, error) {
+	f := strings.Fields(d.Value)
+	if d.Name != "sequence-region" || len(f) != 3 {
+		return "", 0, 0, fmt.Errorf("invalid sequence-region %q", d.Value)
+	}
+	start, err := strconv.Atoi(f[1])
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid sequence-region start %s", err)
+	}
+	end, err := strconv.Atoi(f[2])
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid sequence-region end %s", err)
+	}
+	return unescape(f[0]), start, end, nil
+}
+
+// Sequence is an entry of the FASTA section
+type Sequence struct {
+	ID          string
+	Description string
+	Residues    string
+}
+
+// Feature is a line of the nine column format, the columns are kept as
+// they are except for the coordinates and the attributes
+type Feature struct {
+	SeqID      string
+	Source     string
+	Type       string
+	Start      int
+	End        int
+	Score      string
+	Strand     string
+	Phase      string
+	Attributes Attributes
+}
+
+// ID returns the ID attribute
+func (f *Feature) ID() string {
+	v, _ := f.Attributes.First("ID")
+	return v
+}
+
+// Parents returns the values of the Parent attribute
+func (f *Feature) Parents() []string {
+	return f.Attributes.Get("Parent")
+}
+
+// Len is the length of the feature
+func (f *Feature) Len() int {
+	return f.End - f.Start + 1
+}
+
+func (f *Feature) String() string {
+	return strings.Join([]string{
+		escapeColumn(f.SeqID),
+		escapeColumn(f.Source),
+		escapeColumn(f.Type),
+		strconv.Itoa(f.Start),
+		strconv.Itoa(f.End),
+		f.Score,
+		f.Strand,
+		f.Phase,
+		f.Attributes.String(),
+	}, "\t")
+}
+
+// Attribute is a tag with one or more values
+type Attribute struct {
+	Tag    string
+	Values []string
+}
+
+// Attributes are the tags of the ninth column in their order
+type Attributes []Attribute
+
+// Get returns all the values of the tag
+func (a Attributes) Get(tag string) []string {
+	for _, at := range a {
+		if at.Tag == tag {
+			return at.Values
+		}
+	}
+	return nil
+}
+
+// First returns the first value of the tag
+func (a Attributes) First(tag string) (string, bool) {
+	v := a.Get(tag)
+	if len(v) == 0 {
+		return "", false
+	}
+	return v[0], true
+}
+
+// Has tells if the tag is present
+func (a Attributes) Has(tag string) bool {
+	for _, at := range a {
+		if at.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Set replaces the values of the tag or adds it at the end
+func (a *Attributes) Set(tag string, values ...string) {
+	for i, at := range *a {
+		if at.Tag == tag {
+			(*a)[i].Values = values
+			return
+		}
+	}
+	*a = append(*a, Attribute{Tag: tag, Values: values})
+}
+
+// Add appends values to the tag
+func (a *Attributes) Add(tag string, values ...string) {
+	for i, at := range *a {
+		if at.Tag == tag {
+			(*a)[i].Values = append((*a)[i].Values, values...)
+			return
+		}
+	}
+	*a = append(*a, Attribute{Tag: tag, Values: values})
+}
+
+// Delete removes the tag
+func (a *Attributes) Delete(tag string) {
+	attrs := (*a)[:0]
+	for _, at := range *a {
+		if at.Tag != tag {
+			attrs = append(attrs, at)
+		}
+	}
+	*a = attrs
+}
+
+// Rename changes the name of a tag, the values are added to the new
+// tag if it is already present
+func (a *Attributes) Rename(from, to string) {
+	values := a.Get(from)
+	if values == nil || from == to {
+		return
+	}
+	a.Delete(from)
+	a.Add(to, values...)
+}
+
+func (a Attributes) String() string {
+	if len(a) == 0 {
+		return "."
+	}
+	tags := make([]string, 0, len(a))
+	for _, at := range a {
+		values := make([]string, 0, len(at.Values))
+		for _, v := range at.Values {
+			values = append(values, escapeAttribute(v))
+		}
+		tags = append(tags, fmt.Sprintf("%s=%s", escapeAttribute(at.Tag), strings.Join(values, ",")))
+	}
+	return strings.Join(tags, ";")
+}
+
+// ParseAttributes reads the ninth column
+func ParseAttributes(s string) (Attributes, error) {
+	attrs := make(Attributes, 0)
+	if s == "." || len(s) == 0 {
+		return attrs, nil
+	}
+	for _, pair := range strings.Split(s, ";") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		i := strings.Index(pair, "=")
+		if i <= 0 {
+			return attrs, fmt.Errorf("attribute %q is not a tag=value pair", pair)
+		}
+		var values []string
+		for _, v := range strings.Split(pair[i+1:], ",") {
+			values = append(values, unescape(v))
+		}
+		attrs.Add(unescape(pair[:i]), values...)
+	}
+	return attrs, nil
+}
+
+// ParseFeature reads a line of the nine column format
+func ParseFeature(line string) (*Feature, error) {
+	cols := strings.Split(line, "\t")
+	if len(cols) != 9 {
+		return nil, fmt.Errorf("expected 9 columns got %d", len(cols))
+	}
+	start, err := strconv.Atoi(cols[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid start %q", cols[3])
+	}
+	end, err := strconv.Atoi(cols[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid end %q", cols[4])
+	}
+	attrs, err := ParseAttributes(cols[8])
+	if err != nil {
+		return nil, err
+	}
+	return &Feature{
+		SeqID:      unescape(cols[0]),
+		Source:     unescape(cols[1]),
+		Type:       unescape(cols[2]),
+		Start:      start,
+		End:        end,
+		Score:      cols[5],
+		Strand:     cols[6],
+		Phase:      cols[7],
+		Attributes: attrs,
+	}, nil
+}
+
+// unescape decodes the %XX sequences, invalid ones are kept as they are
+func unescape(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func escape(s string, reserved string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c == 0x7f || c == '%' || strings.IndexByte(reserved, c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func escapeColumn(s string) string {
+	return escape(s, "")
+}
+
+func escapeAttribute(s string) string {
+	return escape(s, ";=&,")
+}
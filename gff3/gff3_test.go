@@ -0,0 +1,214 @@
+package gff3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, r io.Reader) []*Record {
+	var records []*Record
+	gr := NewReader(r)
+	for {
+		rec, err := gr.Read()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, rec)
+	}
+}
+
+func TestReader(t *testing.T) {
+	f, err := os.Open("testdata/sample.gff3")
+	require.NoError(t, err)
+	defer f.Close()
+	records := readAll(t, f)
+	require.Len(t, records, 14)
+	t.Run("directives", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.Equal(DirectiveKind, records[0].Kind)
+		assert.Equal(&Directive{Name: "gff-version", Value: "3"}, records[0].Directive)
+		seqid, start, end, err := records[1].Directive.SequenceRegion()
+		assert.NoError(err)
+		assert.Equal("DDB0232428", seqid)
+		assert.Equal(1, start)
+		assert.Equal(240, end)
+		assert.Equal("#", records[10].Directive.Name, "should read the ### directive")
+		assert.Equal("FASTA", records[11].Directive.Name)
+	})
+	t.Run("comment", func(t *testing.T) {
+		assert.Equal(t, CommentKind, records[2].Kind)
+		assert.Equal(t, " generated by chado2dictycanonicalgff3", records[2].Comment)
+	})
+	t.Run("attributes", func(t *testing.T) {
+		assert := assert.New(t)
+		gene := records[3].Feature
+		assert.Equal(4, records[3].Line)
+		assert.Equal("DDB_G0267178", gene.ID())
+		name, _ := gene.Attributes.First("Name")
+		assert.Equal("abc;def", name, "should decode the escaped value")
+		assert.Equal([]string{"GeneID:8615876", "UniProtKB:Q55H43"}, gene.Attributes.Get("Dbxref"))
+		note, _ := records[4].Feature.Attributes.First("Note")
+		assert.Equal("a,b c", note)
+		assert.Equal([]string{"DDB0216437", "DDB0216438"}, records[6].Feature.Parents())
+		assert.Equal("0", records[7].Feature.Phase)
+		assert.Equal(91, records[7].Feature.Len())
+	})
+	t.Run("fasta", func(t *testing.T) {
+		assert := assert.New(t)
+		seq := records[12].Sequence
+		assert.Equal("DDB0232428", seq.ID)
+		assert.Equal("chromosome 1", seq.Description)
+		assert.Len(seq.Residues, 68)
+		assert.Equal(&Sequence{ID: "DDB0216437_poly", Residues: "MKLV"}, records[13].Sequence)
+		assert.Equal(SequenceKind, records[13].Kind)
+	})
+}
+
+func TestSyntaxError(t *testing.T) {
+	in := "##gff-version 3\nchr1\tsrc\tgene\n" +
+		"chr1\tsrc\tgene\tx\t10\t.\t+\t.\tID=a\n" +
+		"chr1\tsrc\tgene\t1\t10\t.\t+\t.\tID\n" +
+		"chr1\tsrc\tgene\t1\t10\t.\t+\t.\tID=b\n"
+	r := NewReader(strings.NewReader(in))
+	_, err := r.Read()
+	require.NoError(t, err)
+	for _, line := range []int{2, 3, 4} {
+		_, err := r.Read()
+		var se *SyntaxError
+		require.True(t, errors.As(err, &se), "should return a syntax error")
+		assert.Equal(t, line, se.Line)
+	}
+	rec, err := r.Read()
+	require.NoError(t, err, "should continue after a syntax error")
+	assert.Equal(t, "b", rec.Feature.ID())
+}
+
+func TestRoundTrip(t *testing.T) {
+	f, err := os.Open("testdata/sample.gff3")
+	require.NoError(t, err)
+	defer f.Close()
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	for _, rec := range readAll(t, f) {
+		require.NoError(t, w.Write(rec))
+	}
+	require.NoError(t, w.Flush())
+	expected, err := ioutil.ReadFile("testdata/sample.gff3")
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), out.String())
+}
+
+func TestAttributes(t *testing.T) {
+	assert := assert.New(t)
+	attrs, err := ParseAttributes("ID=g1;Dbxref=DDB:1,GO:2;Note=x=y%3B%20;")
+	require.NoError(t, err)
+	note, _ := attrs.First("Note")
+	assert.Equal("x=y; ", note)
+	attrs.Rename("Note", "Comment")
+	attrs.Add("Dbxref", "SO:3")
+	attrs.Set("ID", "g2")
+	attrs.Delete("Missing")
+	assert.Equal("ID=g2;Dbxref=DDB:1,GO:2,SO:3;Comment=x%3Dy%3B ", attrs.String())
+	attrs.Delete("Dbxref")
+	attrs.Delete("Comment")
+	attrs.Delete("ID")
+	assert.Equal(".", attrs.String())
+}
+
+func TestIndex(t *testing.T) {
+	f, err := os.Open("testdata/sample.gff3")
+	require.NoError(t, err)
+	defer f.Close()
+	var features []*Feature
+	for _, rec := range readAll(t, f) {
+		if rec.Kind == FeatureKind {
+			features = append(features, rec.Feature)
+		}
+	}
+	ix := NewIndex(features)
+	assert := assert.New(t)
+	assert.Len(ix.Roots(), 2, "should find the gene and the polypeptide")
+	assert.Len(ix.Children("DDB_G0267178"), 2)
+	assert.Len(ix.Features("cds1"), 2, "should keep all the lines of a feature")
+	assert.Len(ix.Descendants(features[0]), 5, "should find every descendant once")
+	assert.Len(ix.Parents(features[3]), 2)
+	orphan := &Feature{Attributes: Attributes{{Tag: "Parent", Values: []string{"none"}}}}
+	assert.Equal([]string{"none"}, ix.Missing(orphan))
+}
+
+func TestOntology(t *testing.T) {
+	assert := assert.New(t)
+	so := DefaultOntology()
+	assert.True(so.IsA("tRNA", "transcript"))
+	assert.True(so.IsA("five_prime_UTR", "mRNA_region"))
+	assert.True(so.IsA("unknown", "unknown"))
+	assert.False(so.IsA("mRNA", "ncRNA"))
+	assert.False(so.IsA("unknown", "gene"))
+	obo := `format-version: 1.2
+
+[Term]
+id: SO:0000673
+name: transcript
+
+[Term]
+id: SO:0000234
+name: mRNA
+is_a: SO:0000673 ! transcript
+
+[Typedef]
+id: part_of
+name: part_of
+`
+	o, err := ParseOBO(strings.NewReader(obo))
+	require.NoError(t, err)
+	assert.True(o.IsA("mRNA", "transcript"))
+	assert.True(o.IsA("SO:0000234", "transcript"), "should accept the accession")
+	assert.False(o.IsA("transcript", "mRNA"))
+}
+
+func TestGenes(t *testing.T) {
+	assert := assert.New(t)
+	var features []*Feature
+	for _, l := range []string{
+		"chr1\tdictyBase\tgene\t1\t90\t.\t+\t.\tID=g1",
+		"chr1\tdictyBase\tmRNA\t1\t90\t.\t+\t.\tID=m1;Parent=g1",
+		"chr1\tdictyBase\tmRNA\t1\t90\t.\t+\t.\tID=m2;Parent=g1",
+		"chr1\tdictyBase\tCDS\t41\t90\t.\t+\t0\tParent=m1,m2",
+		"chr1\tdictyBase\tfive_prime_UTR\t1\t40\t.\t+\t.\tParent=m1",
+		"chr1\tdictyBase\tpolypeptide\t41\t90\t.\t+\t.\tID=p1;Derives_from=m1",
+	} {
+		f, err := ParseFeature(l)
+		require.NoError(t, err)
+		features = append(features, f)
+	}
+	genes := NewIndex(features).Genes(DefaultOntology())
+	require.Len(t, genes, 1)
+	assert.Equal("g1", genes[0].Feature.ID())
+	require.Len(t, genes[0].Transcripts, 2)
+	m1 := genes[0].Transcripts[0]
+	require.Len(t, m1.Exons, 1, "should join the touching UTR and CDS")
+	assert.Equal(1, m1.Exons[0].Start)
+	assert.Equal(90, m1.Exons[0].End)
+	start, end, ok := m1.CodingRange()
+	assert.True(ok)
+	assert.Equal([]int{41, 90}, []int{start, end})
+	assert.Len(genes[0].Transcripts[1].Exons, 1)
+}
+
+func TestSequence(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("CTATTCGCAATGCCACAT", ReverseComplement("ATGTGGCATTGCGAATAG"))
+	assert.Equal("nrY", ReverseComplement("Ryn"))
+	assert.Equal("MWHCE*", Translate("ATGTGGCATTGCGAATAG"))
+	assert.Equal("MX", Translate("augNNCA"))
+	assert.Equal("", Translate("AT"))
+}
@@ -0,0 +1,93 @@
+package gff3
+
+// Index links the features through their ID and Parent attributes,
+// features that are split over several lines share the same ID
+type Index struct {
+	byID     map[string][]*Feature
+	children map[string][]*Feature
+	features []*Feature
+}
+
+// NewIndex indexes the features
+func NewIndex(features []*Feature) *Index {
+	ix := &Index{
+		byID:     make(map[string][]*Feature),
+		children: make(map[string][]*Feature),
+		features: features,
+	}
+	for _, f := range features {
+		if id := f.ID(); len(id) > 0 {
+			ix.byID[id] = append(ix.byID[id], f)
+		}
+		for _, p := range f.Parents() {
+			ix.children[p] = append(ix.children[p], f)
+		}
+	}
+	return ix
+}
+
+// Features returns all the features of the given ID
+func (ix *Index) Features(id string) []*Feature {
+	return ix.byID[id]
+}
+
+// Children returns the features that have the ID as parent
+func (ix *Index) Children(id string) []*Feature {
+	return ix.children[id]
+}
+
+// Parents returns the parent features, parents that are not
+// present are left out
+func (ix *Index) Parents(f *Feature) []*Feature {
+	var parents []*Feature
+	for _, p := range f.Parents() {
+		parents = append(parents, ix.byID[p]...)
+	}
+	return parents
+}
+
+// Missing returns the parent IDs of the feature that are not present
+func (ix *Index) Missing(f *Feature) []string {
+	var missing []string
+	for _, p := range f.Parents() {
+		if _, ok := ix.byID[p]; !ok {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// Roots returns the features without a parent in their original order
+func (ix *Index) Roots() []*Feature {
+	var roots []*Feature
+	for _, f := range ix.features {
+		if len(f.Parents()) == 0 {
+			roots = append(roots, f)
+		}
+	}
+	return roots
+}
+
+// Descendants returns the children, grandchildren and so on of the
+// feature, every feature is returned once even if it has many parents
+func (ix *Index) Descendants(f *Feature) []*Feature {
+	var desc []*Feature
+	seen := map[*Feature]bool{f: true}
+	queue := []string{f.ID()}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if len(id) == 0 {
+			continue
+		}
+		for _, c := range ix.children[id] {
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+			desc = append(desc, c)
+			queue = append(queue, c.ID())
+		}
+	}
+	return desc
+}
@@ -0,0 +1,111 @@
+package gff3
+
+import "sort"
+
+// Transcript is a feature with exon, CDS or UTR children, the parts are
+// sorted by their start
+type Transcript struct {
+	Feature *Feature
+	Exons   []*Feature
+	CDS     []*Feature
+	UTRs    []*Feature
+}
+
+// Gene is the parent of one or more transcripts, a transcript without
+// a parent is its own gene
+type Gene struct {
+	Feature     *Feature
+	Transcripts []*Transcript
+}
+
+// CodingRange returns the span of the coding segments
+func (t *Transcript) CodingRange() (int, int, bool) {
+	if len(t.CDS) == 0 {
+		return 0, 0, false
+	}
+	start, end := t.CDS[0].Start, t.CDS[0].End
+	for _, c := range t.CDS[1:] {
+		if c.End > end {
+			end = c.End
+		}
+	}
+	return start, end, true
+}
+
+// Genes groups the transcripts of the index by their gene in the order the
+// genes are found. The exons are made from the CDS and UTRs when the
+// transcript has none.
+func (ix *Index) Genes(so *Ontology) []*Gene {
+	var genes []*Gene
+	byGene := make(map[*Feature]*Gene)
+	seen := make(map[string]bool)
+	for _, f := range ix.features {
+		id := f.ID()
+		if len(id) == 0 || seen[id] {
+			continue
+		}
+		t := &Transcript{Feature: f}
+		for _, c := range ix.children[id] {
+			switch {
+			case so.IsA(c.Type, "exon") || c.Type == "pseudogenic_exon":
+				t.Exons = append(t.Exons, c)
+			case so.IsA(c.Type, "CDS"):
+				t.CDS = append(t.CDS, c)
+			case so.IsA(c.Type, "UTR"):
+				t.UTRs = append(t.UTRs, c)
+			}
+		}
+		if len(t.Exons)+len(t.CDS)+len(t.UTRs) == 0 {
+			continue
+		}
+		seen[id] = true
+		for _, parts := range [][]*Feature{t.Exons, t.CDS, t.UTRs} {
+			sortByStart(parts)
+		}
+		if len(t.Exons) == 0 {
+			t.Exons = joinSegments(append(append([]*Feature{}, t.CDS...), t.UTRs...))
+		}
+		gf := f
+		if parents := ix.Parents(f); len(parents) > 0 {
+			gf = parents[0]
+		}
+		g, ok := byGene[gf]
+		if !ok {
+			g = &Gene{Feature: gf}
+			byGene[gf] = g
+			genes = append(genes, g)
+		}
+		g.Transcripts = append(g.Transcripts, t)
+	}
+	return genes
+}
+
+func sortByStart(parts []*Feature) {
+	sort.SliceStable(parts, func(i, j int) bool { return parts[i].Start < parts[j].Start })
+}
+
+// joinSegments makes exons from the segments, touching or overlapping
+// segments become a single exon
+func joinSegments(parts []*Feature) []*Feature {
+	sortByStart(parts)
+	var exons []*Feature
+	for _, p := range parts {
+		if n := len(exons); n > 0 && p.Start <= exons[n-1].End+1 {
+			if p.End > exons[n-1].End {
+				exons[n-1].End = p.End
+			}
+			continue
+		}
+		exons = append(exons, &Feature{
+			SeqID:  p.SeqID,
+			Source: p.Source,
+			Type:   "exon",
+			Start:  p.Start,
+			End:    p.End,
+			Score:  ".",
+			Strand: p.Strand,
+			Phase:  ".",
+		})
+	}
+	return exons
+}
@@ -0,0 +1,143 @@
+package gff3
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maximum length of a line, sequences of the FASTA section are usually
+// wrapped but some files have a whole chromosome in a single line
+const maxLineSize = 512 * 1024 * 1024
+
+// SyntaxError is a line that could not be parsed, the reader could be
+// used after it to continue with the next line
+type SyntaxError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads the records of a GFF3 stream one at a time
+type Reader struct {
+	s       *bufio.Scanner
+	line    int
+	fasta   bool
+	pending string
+	hasNext bool
+}
+
+// NewReader returns a reader of the stream
+func NewReader(r io.Reader) *Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &Reader{s: s}
+}
+
+// Line is the line number of the last read record
+func (r *Reader) Line() int {
+	return r.line
+}
+
+func (r *Reader) next() (string, bool) {
+	if r.hasNext {
+		r.hasNext = false
+		return r.pending, true
+	}
+	if !r.s.Scan() {
+		return "", false
+	}
+	r.line++
+	return strings.TrimRight(r.s.Text(), "\r"), true
+}
+
+func (r *Reader) unread(line string) {
+	r.pending = line
+	r.hasNext = true
+}
+
+// Read returns the next record, blank lines are skipped. It returns io.EOF
+// at the end of the stream and a *SyntaxError for a malformed line.
+func (r *Reader) Read() (*Record, error) {
+	for {
+		line, ok := r.next()
+		if !ok {
+			if err := r.s.Err(); err != nil {
+				return nil, fmt.Errorf("unable to read line %d %s", r.line+1, err)
+			}
+			return nil, io.EOF
+		}
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		if r.fasta || strings.HasPrefix(line, ">") {
+			r.fasta = true
+			return r.readSequence(line)
+		}
+		switch {
+		case strings.HasPrefix(line, "##"):
+			d := parseDirective(line)
+			if d.Name == "FASTA" {
+				r.fasta = true
+			}
+			return &Record{Kind: DirectiveKind, Line: r.line, Directive: d}, nil
+		case strings.HasPrefix(line, "#"):
+			return &Record{Kind: CommentKind, Line: r.line, Comment: line[1:]}, nil
+		}
+		f, err := ParseFeature(line)
+		if err != nil {
+			return nil, &SyntaxError{Line: r.line, Text: line, Err: err}
+		}
+		return &Record{Kind: FeatureKind, Line: r.line, Feature: f}, nil
+	}
+}
+
+func (r *Reader) readSequence(header string) (*Record, error) {
+	start := r.line
+	if !strings.HasPrefix(header, ">") {
+		return nil, &SyntaxError{
+			Line: start,
+			Text: header,
+			Err:  fmt.Errorf("expected a fasta header"),
+		}
+	}
+	seq := &Sequence{ID: strings.TrimSpace(header[1:])}
+	if i := strings.IndexAny(seq.ID, " \t"); i >= 0 {
+		seq.ID, seq.Description = seq.ID[:i], strings.TrimSpace(seq.ID[i+1:])
+	}
+	var b strings.Builder
+	for {
+		line, ok := r.next()
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(line, ">") {
+			r.unread(line)
+			break
+		}
+		b.WriteString(strings.TrimSpace(line))
+	}
+	seq.Residues = b.String()
+	return &Record{Kind: SequenceKind, Line: start, Sequence: seq}, nil
+}
+
+func parseDirective(line string) *Directive {
+	body := strings.TrimPrefix(line, "##")
+	if strings.HasPrefix(body, "#") {
+		return &Directive{Name: "#"}
+	}
+	d := &Directive{Name: body}
+	if i := strings.IndexAny(body, " \t"); i >= 0 {
+		d.Name, d.Value = body[:i], strings.TrimSpace(body[i+1:])
+	}
+	return d
+}
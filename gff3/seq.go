@@ -0,0 +1,51 @@
+package gff3
+
+import "strings"
+
+// complements of the IUPAC nucleotide codes
+var complement = strings.NewReplacer(
+	"A", "T", "T", "A", "G", "C", "C", "G", "U", "A",
+	"R", "Y", "Y", "R", "K", "M", "M", "K", "B", "V", "V", "B", "D", "H", "H", "D",
+	"a", "t", "t", "a", "g", "c", "c", "g", "u", "a",
+	"r", "y", "y", "r", "k", "m", "m", "k", "b", "v", "v", "b", "d", "h", "h", "d",
+)
+
+// ReverseComplement returns the other strand of the sequence
+func ReverseComplement(s string) string {
+	b := []byte(complement.Replace(s))
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+// the standard genetic code, the codons are in TCAG order
+const (
+	codonBases = "TCAG"
+	aminoAcids = "FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"
+)
+
+// Translate translates the coding sequence with the standard genetic code,
+// codons with unknown bases become X and an incomplete codon at the end
+// is left out
+func Translate(s string) string {
+	s = strings.Replace(strings.ToUpper(s), "U", "T", -1)
+	var b strings.Builder
+	for i := 0; i+3 <= len(s); i += 3 {
+		idx := 0
+		for _, c := range s[i : i+3] {
+			n := strings.IndexRune(codonBases, c)
+			if n < 0 {
+				idx = -1
+				break
+			}
+			idx = idx*4 + n
+		}
+		if idx < 0 {
+			b.WriteByte('X')
+			continue
+		}
+		b.WriteByte(aminoAcids[idx])
+	}
+	return b.String()
+}
@@ -0,0 +1,152 @@
+package gff3
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// is_a relations of the sequence ontology terms that are found in the
+// dictyBase exports, the full ontology could be read with ParseOBO
+var builtinSO = [][2]string{
+	{"protein_coding_gene", "gene"},
+	{"ncRNA_gene", "gene"},
+	{"gene", "biological_region"},
+	{"pseudogene", "biological_region"},
+	{"transcript", "gene_member_region"},
+	{"primary_transcript", "transcript"},
+	{"mature_transcript", "transcript"},
+	{"mRNA", "mature_transcript"},
+	{"ncRNA", "transcript"},
+	{"tRNA", "ncRNA"},
+	{"rRNA", "ncRNA"},
+	{"snRNA", "ncRNA"},
+	{"snoRNA", "ncRNA"},
+	{"SRP_RNA", "ncRNA"},
+	{"RNase_P_RNA", "ncRNA"},
+	{"class_I_RNA", "ncRNA"},
+	{"class_II_RNA", "ncRNA"},
+	{"pseudogenic_transcript", "biological_region"},
+	{"pseudogenic_exon", "biological_region"},
+	{"transcript_region", "biological_region"},
+	{"exon", "transcript_region"},
+	{"intron", "transcript_region"},
+	{"mature_transcript_region", "transcript_region"},
+	{"mRNA_region", "mature_transcript_region"},
+	{"CDS", "mRNA_region"},
+	{"UTR", "mRNA_region"},
+	{"five_prime_UTR", "UTR"},
+	{"three_prime_UTR", "UTR"},
+	{"polypeptide", "biological_region"},
+	{"match", "biological_region"},
+	{"match_part", "biological_region"},
+	{"nucleotide_match", "match"},
+	{"protein_match", "match"},
+	{"EST_match", "nucleotide_match"},
+	{"cDNA_match", "nucleotide_match"},
+	{"translated_nucleotide_match", "match"},
+	{"chromosome", "biological_region"},
+	{"contig", "biological_region"},
+	{"supercontig", "biological_region"},
+}
+
+// Ontology keeps the is_a relations of sequence ontology terms, a term
+// could be referred by its name or its id
+type Ontology struct {
+	parents map[string][]string
+	ids     map[string]string
+}
+
+func newOntology() *Ontology {
+	return &Ontology{
+		parents: make(map[string][]string),
+		ids:     make(map[string]string),
+	}
+}
+
+// DefaultOntology returns the builtin subset of the sequence ontology
+func DefaultOntology() *Ontology {
+	o := newOntology()
+	for _, r := range builtinSO {
+		o.ids[r[0]], o.ids[r[1]] = r[0], r[1]
+		o.parents[r[0]] = append(o.parents[r[0]], r[1])
+	}
+	return o
+}
+
+// ParseOBO reads the terms and is_a relations of an obo file
+func ParseOBO(r io.Reader) (*Ontology, error) {
+	o := newOntology()
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	var id string
+	inTerm := false
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "[") {
+			inTerm = line == "[Term]"
+			id = ""
+			continue
+		}
+		if !inTerm {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key, value := line[:i], strings.TrimSpace(line[i+1:])
+		if j := strings.Index(value, " !"); j >= 0 {
+			value = strings.TrimSpace(value[:j])
+		}
+		switch key {
+		case "id":
+			id = value
+			o.ids[id] = id
+		case "name":
+			if len(id) > 0 {
+				o.ids[value] = id
+			}
+		case "is_a":
+			if len(id) > 0 {
+				o.parents[id] = append(o.parents[id], value)
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read obo %s", err)
+	}
+	return o, nil
+}
+
+// IsA tells if the term is the ancestor or one of its descendants,
+// unknown terms only match themselves
+func (o *Ontology) IsA(term, ancestor string) bool {
+	if term == ancestor {
+		return true
+	}
+	from, ok := o.ids[term]
+	if !ok {
+		return false
+	}
+	to, ok := o.ids[ancestor]
+	if !ok {
+		return false
+	}
+	seen := make(map[string]bool)
+	queue := []string{from}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		if t == to {
+			return true
+		}
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		queue = append(queue, o.parents[t]...)
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package gff3
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// StatsRow is the number of features of a type from a source on a sequence
+type StatsRow struct {
+	SeqID  string `json:"seqid"`
+	Type   string `json:"type"`
+	Source string `json:"source"`
+	Count  int    `json:"count"`
+}
+
+// Stats counts the features of a file by seqid, type and source
+type Stats struct {
+	File      string         `json:"file"`
+	Features  int            `json:"features"`
+	Sequences int            `json:"sequences"`
+	Invalid   int            `json:"invalid"`
+	SeqIDs    map[string]int `json:"seqids"`
+	Types     map[string]int `json:"types"`
+	Sources   map[string]int `json:"sources"`
+	Rows      []StatsRow     `json:"rows"`
+}
+
+// CountFeatures reads the file and counts its features, lines that
+// could not be parsed are only counted as invalid
+func CountFeatures(in io.Reader) (*Stats, error) {
+	st := &Stats{
+		SeqIDs:  make(map[string]int),
+		Types:   make(map[string]int),
+		Sources: make(map[string]int),
+		Rows:    make([]StatsRow, 0),
+	}
+	rows := make(map[StatsRow]int)
+	r := NewReader(in)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if _, ok := err.(*SyntaxError); ok {
+			st.Invalid++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch rec.Kind {
+		case FeatureKind:
+			f := rec.Feature
+			st.Features++
+			st.SeqIDs[f.SeqID]++
+			st.Types[f.Type]++
+			st.Sources[f.Source]++
+			rows[StatsRow{SeqID: f.SeqID, Type: f.Type, Source: f.Source}]++
+		case SequenceKind:
+			st.Sequences++
+		}
+	}
+	for row, n := range rows {
+		row.Count = n
+		st.Rows = append(st.Rows, row)
+	}
+	sort.Slice(st.Rows, func(i, j int) bool {
+		a, b := st.Rows[i], st.Rows[j]
+		switch {
+		case a.SeqID != b.SeqID:
+			return a.SeqID < b.SeqID
+		case a.Type != b.Type:
+			return a.Type < b.Type
+		}
+		return a.Source < b.Source
+	})
+	return st, nil
+}
+
+// WriteJSON writes the stats as indented json
+func (st *Stats) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(st)
+}
+
+// WriteText writes the totals followed by a table of the counts
+func (st *Stats) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "file\t%s\n", st.File)
+	fmt.Fprintf(tw, "features\t%d\n", st.Features)
+	fmt.Fprintf(tw, "sequences\t%d\n", st.Sequences)
+	fmt.Fprintf(tw, "invalid\t%d\n", st.Invalid)
+	for _, t := range []struct {
+		name   string
+		counts map[string]int
+	}{{"seqid", st.SeqIDs}, {"type", st.Types}, {"source", st.Sources}} {
+		keys := make([]string, 0, len(t.counts))
+		for k := range t.counts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(tw, "\n%s\tcount\n", t.name)
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s\t%d\n", k, t.counts[k])
+		}
+	}
+	fmt.Fprintln(tw, "\nseqid\ttype\tsource\tcount")
+	for _, r := range st.Rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", r.SeqID, r.Type, r.Source, r.Count)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,272 @@
+package gff3
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// severities of the validation issues
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue is a problem found in a line of the file
+type Issue struct {
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	ID       string `json:"id,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Report is the outcome of the validation of a file
+type Report struct {
+	File     string  `json:"file"`
+	Features int     `json:"features"`
+	Errors   int     `json:"errors"`
+	Warnings int     `json:"warnings"`
+	Issues   []Issue `json:"issues"`
+}
+
+type firstSeen struct {
+	line   int
+	seqid  string
+	ftype  string
+	strand string
+}
+
+type parentRef struct {
+	line   int
+	id     string
+	parent string
+}
+
+type cdsSegment struct {
+	line   int
+	start  int
+	end    int
+	strand string
+	phase  int
+}
+
+type region struct {
+	start int
+	end   int
+}
+
+// validator keeps the state that is needed for the checks spanning
+// more than one line
+type validator struct {
+	report  *Report
+	ids     map[string]firstSeen
+	refs    []parentRef
+	cds     map[string][]cdsSegment
+	cdsKeys []string
+	regions map[string]region
+}
+
+// Validate checks the column count, coordinates, strand and phase values,
+// unique IDs, dangling Parent references, the phase of CDS segments and
+// the sequence-region bounds of every feature
+func Validate(in io.Reader) (*Report, error) {
+	v := &validator{
+		report:  &Report{Issues: make([]Issue, 0)},
+		ids:     make(map[string]firstSeen),
+		cds:     make(map[string][]cdsSegment),
+		regions: make(map[string]region),
+	}
+	r := NewReader(in)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		var se *SyntaxError
+		if errors.As(err, &se) {
+			v.syntax(se)
+			continue
+		}
+		if err != nil {
+			return v.report, err
+		}
+		switch rec.Kind {
+		case DirectiveKind:
+			v.directive(rec)
+		case FeatureKind:
+			v.feature(rec)
+		}
+	}
+	v.finish()
+	return v.report, nil
+}
+
+func (v *validator) add(line int, severity, check, id, format string, args ...interface{}) {
+	v.report.Issues = append(v.report.Issues, Issue{
+		Line:     line,
+		Severity: severity,
+		Check:    check,
+		ID:       id,
+		Message:  fmt.Sprintf(format, args...),
+	})
+	if severity == SeverityError {
+		v.report.Errors++
+	} else {
+		v.report.Warnings++
+	}
+}
+
+func (v *validator) syntax(se *SyntaxError) {
+	if n := strings.Count(se.Text, "\t") + 1; n != 9 {
+		v.add(se.Line, SeverityError, "columns", "", "expected 9 columns got %d", n)
+		return
+	}
+	v.add(se.Line, SeverityError, "syntax", "", "%s", se.Err)
+}
+
+func (v *validator) directive(rec *Record) {
+	if rec.Directive.Name != "sequence-region" {
+		return
+	}
+	seqid, start, end, err := rec.Directive.SequenceRegion()
+	if err != nil {
+		v.add(rec.Line, SeverityError, "sequence-region", "", "%s", err)
+		return
+	}
+	if _, ok := v.regions[seqid]; ok {
+		v.add(rec.Line, SeverityWarning, "sequence-region", seqid, "sequence-region of %s is given more than once", seqid)
+	}
+	v.regions[seqid] = region{start: start, end: end}
+}
+
+func (v *validator) feature(rec *Record) {
+	f := rec.Feature
+	id := f.ID()
+	v.report.Features++
+	switch {
+	case f.Start < 1:
+		v.add(rec.Line, SeverityError, "coordinates", id, "start %d is less than 1", f.Start)
+	case f.Start > f.End:
+		v.add(rec.Line, SeverityError, "coordinates", id, "start %d is greater than end %d", f.Start, f.End)
+	}
+	if r, ok := v.regions[f.SeqID]; ok && (f.Start < r.start || f.End > r.end) {
+		v.add(
+			rec.Line, SeverityError, "sequence-region", id,
+			"%d-%d is outside of the sequence-region %s %d-%d",
+			f.Start, f.End, f.SeqID, r.start, r.end,
+		)
+	}
+	switch f.Strand {
+	case "+", "-", ".", "?":
+	default:
+		v.add(rec.Line, SeverityError, "strand", id, "invalid strand %q", f.Strand)
+	}
+	phase, perr := strconv.Atoi(f.Phase)
+	switch {
+	case f.Phase == "." && f.Type == "CDS":
+		v.add(rec.Line, SeverityError, "phase", id, "CDS has no phase")
+	case f.Phase == ".":
+	case perr != nil || phase < 0 || phase > 2:
+		v.add(rec.Line, SeverityError, "phase", id, "invalid phase %q", f.Phase)
+	case f.Type != "CDS":
+		v.add(rec.Line, SeverityWarning, "phase", id, "phase is given for a %s", f.Type)
+	}
+	if len(id) > 0 {
+		if first, ok := v.ids[id]; ok {
+			if first.seqid != f.SeqID || first.ftype != f.Type || first.strand != f.Strand {
+				v.add(rec.Line, SeverityError, "duplicate-id", id, "ID %s is already used in line %d", id, first.line)
+			}
+		} else {
+			v.ids[id] = firstSeen{line: rec.Line, seqid: f.SeqID, ftype: f.Type, strand: f.Strand}
+		}
+	}
+	for _, p := range f.Parents() {
+		v.refs = append(v.refs, parentRef{line: rec.Line, id: id, parent: p})
+	}
+	if f.Type == "CDS" && perr == nil && f.Start <= f.End {
+		key := id
+		if len(key) == 0 {
+			key = "Parent=" + strings.Join(f.Parents(), ",")
+		}
+		if _, ok := v.cds[key]; !ok {
+			v.cdsKeys = append(v.cdsKeys, key)
+		}
+		v.cds[key] = append(v.cds[key], cdsSegment{
+			line: rec.Line, start: f.Start, end: f.End,
+			strand: f.Strand, phase: phase,
+		})
+	}
+}
+
+func (v *validator) finish() {
+	for _, ref := range v.refs {
+		if _, ok := v.ids[ref.parent]; !ok {
+			v.add(ref.line, SeverityError, "dangling-parent", ref.id, "parent %s does not exist", ref.parent)
+		}
+	}
+	for _, key := range v.cdsKeys {
+		v.checkPhases(key, v.cds[key])
+	}
+	sort.SliceStable(v.report.Issues, func(i, j int) bool {
+		return v.report.Issues[i].Line < v.report.Issues[j].Line
+	})
+}
+
+// checkPhases compares the phase of every CDS segment with the one
+// expected from the previous segment in the order of translation
+func (v *validator) checkPhases(key string, segs []cdsSegment) {
+	if len(segs) < 2 {
+		return
+	}
+	reverse := segs[0].strand == "-"
+	sort.SliceStable(segs, func(i, j int) bool {
+		if reverse {
+			return segs[i].start > segs[j].start
+		}
+		return segs[i].start < segs[j].start
+	})
+	id := key
+	if strings.HasPrefix(key, "Parent=") {
+		id = ""
+	}
+	for i := 1; i < len(segs); i++ {
+		prev := segs[i-1]
+		expected := (3 - ((prev.end - prev.start + 1 - prev.phase) % 3)) % 3
+		if segs[i].phase != expected {
+			v.add(
+				segs[i].line, SeverityError, "cds-phase", id,
+				"phase %d does not follow the previous segment in line %d, expected %d",
+				segs[i].phase, prev.line, expected,
+			)
+		}
+	}
+}
+
+// WriteJSON writes the report as an indented json document
+func (rp *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rp)
+}
+
+// WriteCSV writes the issues of the report with a header
+func (rp *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"line", "severity", "check", "id", "message"}); err != nil {
+		return err
+	}
+	for _, is := range rp.Issues {
+		err := cw.Write([]string{strconv.Itoa(is.Line), is.Severity, is.Check, is.ID, is.Message})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,66 @@
+package gff3
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		f, err := os.Open("testdata/sample.gff3")
+		require.NoError(t, err)
+		defer f.Close()
+		rp, err := Validate(f)
+		require.NoError(t, err)
+		assert.Equal(t, 7, rp.Features)
+		assert.Empty(t, rp.Issues)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		f, err := os.Open("testdata/invalid.gff3")
+		require.NoError(t, err)
+		defer f.Close()
+		rp, err := Validate(f)
+		require.NoError(t, err)
+		assert := assert.New(t)
+		assert.Equal(10, rp.Errors)
+		assert.Equal(1, rp.Warnings)
+		var checks []string
+		for _, is := range rp.Issues {
+			checks = append(checks, is.Check)
+		}
+		assert.Equal([]string{
+			"coordinates", "sequence-region", "strand", "phase", "cds-phase",
+			"phase", "duplicate-id", "dangling-parent", "columns", "syntax", "phase",
+		}, checks)
+		assert.Equal(Issue{
+			Line: 9, Severity: SeverityError, Check: "cds-phase", ID: "c2",
+			Message: "phase 0 does not follow the previous segment in line 8, expected 2",
+		}, rp.Issues[4])
+		assert.Equal(12, rp.Issues[7].Line, "should report the line of the child")
+		assert.Equal(SeverityWarning, rp.Issues[10].Severity)
+	})
+	t.Run("report", func(t *testing.T) {
+		rp := &Report{
+			File:   "x.gff3",
+			Errors: 1,
+			Issues: []Issue{{Line: 3, Severity: SeverityError, Check: "strand", ID: "g1", Message: `invalid strand "x"`}},
+		}
+		var js bytes.Buffer
+		require.NoError(t, rp.WriteJSON(&js))
+		var decoded Report
+		require.NoError(t, json.Unmarshal(js.Bytes(), &decoded))
+		assert.Equal(t, *rp, decoded)
+		var cs bytes.Buffer
+		require.NoError(t, rp.WriteCSV(&cs))
+		assert.Equal(t, []string{
+			"line,severity,check,id,message",
+			`3,error,strand,g1,"invalid strand ""x"""`,
+		}, strings.Split(strings.TrimSpace(cs.String()), "\n"))
+	})
+}
@@ -0,0 +1,102 @@
+package gff3
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// LineWidth is the default width of the sequence lines
+const LineWidth = 60
+
+// Writer writes records in GFF3 format, the ##FASTA directive is added
+// before the first sequence if it was not written
+type Writer struct {
+	w         *bufio.Writer
+	LineWidth int
+	fasta     bool
+}
+
+// NewWriter returns a buffered writer, Flush has to be called at the end
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), LineWidth: LineWidth}
+}
+
+// NewFastaWriter returns a writer for plain FASTA files, the sequences
+// are written without the ##FASTA directive
+func NewFastaWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), LineWidth: LineWidth, fasta: true}
+}
+
+// Write writes a record of any kind
+func (w *Writer) Write(r *Record) error {
+	switch r.Kind {
+	case FeatureKind:
+		return w.WriteFeature(r.Feature)
+	case DirectiveKind:
+		return w.WriteDirective(r.Directive)
+	case CommentKind:
+		return w.WriteComment(r.Comment)
+	case SequenceKind:
+		return w.WriteSequence(r.Sequence)
+	}
+	return fmt.Errorf("unknown record kind %d", r.Kind)
+}
+
+// WriteFeature writes a feature line
+func (w *Writer) WriteFeature(f *Feature) error {
+	_, err := fmt.Fprintln(w.w, f.String())
+	return err
+}
+
+// WriteDirective writes a ## line
+func (w *Writer) WriteDirective(d *Directive) error {
+	if d.Name == "FASTA" {
+		if w.fasta {
+			return nil
+		}
+		w.fasta = true
+	}
+	_, err := fmt.Fprintln(w.w, d.String())
+	return err
+}
+
+// WriteComment writes a # line
+func (w *Writer) WriteComment(c string) error {
+	_, err := fmt.Fprintf(w.w, "#%s\n", c)
+	return err
+}
+
+// WriteSequence writes a FASTA entry with the residues wrapped
+// at the line width
+func (w *Writer) WriteSequence(s *Sequence) error {
+	if err := w.WriteDirective(&Directive{Name: "FASTA"}); err != nil {
+		return err
+	}
+	header := ">" + s.ID
+	if len(s.Description) > 0 {
+		header += " " + s.Description
+	}
+	if _, err := fmt.Fprintln(w.w, header); err != nil {
+		return err
+	}
+	width := w.LineWidth
+	if width <= 0 {
+		width = len(s.Residues)
+	}
+	for i := 0; i < len(s.Residues); i += width {
+		end := i + width
+		if end > len(s.Residues) {
+			end = len(s.Residues)
+		}
+		if _, err := fmt.Fprintln(w.w, s.Residues[i:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes the buffered data
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
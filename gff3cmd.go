@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/migration-data-export/gff3"
+	"github.com/migration-data-export/runner"
+	"github.com/urfave/cli"
+)
+
+// transformGFF3 streams the records of a gff3 file through the function
+// that writes them out
+func transformGFF3(in io.Reader, fn func(*gff3.Record) error) error {
+	r := gff3.NewReader(in)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error in reading gff3 %s", err)
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+func ValidateGFF3Action(c *cli.Context) error {
+	if !c.IsSet("input") {
+		return cli.NewExitError("argument input is required", 2)
+	}
+	rp, err := ValidateGFF3File(c.String("input"), c.String("output"), c.String("format"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	if rp.Errors > 0 {
+		return cli.NewExitError(
+			fmt.Sprintf("%d errors and %d warnings found in %s", rp.Errors, rp.Warnings, rp.File),
+			2,
+		)
+	}
+	return nil
+}
+
+// ValidateGFF3File validates a gff3 file and writes the report in json or
+// csv format to the output file, or to stdout if no output is given
+func ValidateGFF3File(input, output, format string) (*gff3.Report, error) {
+	in, err := os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("error in opening file %s", err)
+	}
+	defer in.Close()
+	rp, err := gff3.Validate(in)
+	if err != nil {
+		return nil, fmt.Errorf("error in validating %s %s", input, err)
+	}
+	rp.File = input
+	out := os.Stdout
+	if len(output) > 0 {
+		out, err = os.Create(output)
+		if err != nil {
+			return nil, fmt.Errorf("error in writing file %s", err)
+		}
+		defer out.Close()
+	}
+	switch format {
+	case "csv":
+		err = rp.WriteCSV(out)
+	default:
+		err = rp.WriteJSON(out)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error in writing report %s", err)
+	}
+	return rp, nil
+}
+
+// validationJobs validates every gff3 output of the jobs, the report is
+// written next to the gff3 file. The gff3 is the input of the job, so it
+// is validated again on resume once the file changes
+func validationJobs(jobs []runner.Job) []runner.Job {
+	var vjobs []runner.Job
+	for _, j := range jobs {
+		for _, o := range j.Outputs {
+			if filepath.Ext(o) != ".gff3" {
+				continue
+			}
+			input := o
+			report := strings.TrimSuffix(input, ".gff3") + ".validation.json"
+			vjobs = append(vjobs, runner.Job{
+				Name:       fmt.Sprintf("validate_%s", j.Name),
+				Subcommand: "validate-gff3",
+				Outputs:    []string{report},
+				Inputs:     []string{input},
+				Run: func(context.Context) error {
+					rp, err := ValidateGFF3File(input, report, "json")
+					if err != nil {
+						return err
+					}
+					if rp.Errors > 0 {
+						return fmt.Errorf("%d errors found in %s, see %s", rp.Errors, input, report)
+					}
+					return nil
+				},
+			})
+		}
+	}
+	return vjobs
+}
+
+func DbxrefCleanUpAction(c *cli.Context) error {
+	if err := ValidateCleanUpArgs(c); err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	in, err := os.Open(c.String("input"))
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("error in opening file %s\n", err),
+			2,
+		)
+	}
+	defer in.Close()
+	out, err := os.Create(c.String("output"))
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("error in writing file %s\n", err),
+			2,
+		)
+	}
+	defer out.Close()
+	if err := CleanDbxref(in, out, c.StringSlice("db-name")); err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	return nil
+}
+
+// CleanDbxref removes the Dbxref entries starting with any of the given
+// database prefixes from every feature, the attribute is removed if no
+// entry is left
+func CleanDbxref(in io.Reader, out io.Writer, db []string) error {
+	rw, err := NewRewriter(&RewriteRules{Dbxref: DbxrefRules{DropPrefixes: db}})
+	if err != nil {
+		return err
+	}
+	_, _, err = RewriteGFF3(in, out, rw)
+	return err
+}
+
+func hasDbPrefix(dbxref string, db []string) bool {
+	for _, n := range db {
+		if strings.HasPrefix(dbxref, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func SplitPolypeptideAction(c *cli.Context) error {
+	if err := ValidatePolypetideArgs(c); err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	in, err := os.Open(c.String("input"))
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("error in opening file %s\n", err),
+			2,
+		)
+	}
+	defer in.Close()
+	genome, poly := MakeOutputName(c.String("input"))
+	gr, err := os.Create(genome)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("error in opening %s file  for wriring %s\n", genome, err),
+			2,
+		)
+	}
+	defer gr.Close()
+	pr, err := os.Create(poly)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("error in opening %s file  for wriring %s\n", poly, err),
+			2,
+		)
+	}
+	defer pr.Close()
+	if err := SplitPolypeptide(in, gr, pr); err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("error in splitting file %s %s\n", c.String("input"), err),
+			2,
+		)
+	}
+	return nil
+}
+
+// SplitPolypeptide writes the polypeptide features to a separate gff3,
+// everything else including the sequences stays with the genome
+func SplitPolypeptide(in io.ReadSeeker, genome, poly io.Writer) error {
+	s := &Splitter{
+		Rules:    []SplitRule{{Types: []string{"polypeptide"}}},
+		Ontology: gff3.DefaultOntology(),
+	}
+	return s.Split(in, []io.Writer{poly}, genome, nil)
+}
+
+func isPolyPeptide(f *gff3.Feature) bool {
+	return f.Type == "polypeptide"
+}
+
+func MakeOutputName(path string) (string, string) {
+	prefix := strings.Split(filepath.Base(path), ".")[0]
+	dir := filepath.Dir(path)
+	return filepath.Join(dir, fmt.Sprintf("%s_no_poly.gff3", prefix)),
+		filepath.Join(dir, fmt.Sprintf("%s_poly.gff3", prefix))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readFixture(t *testing.T, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "gff3", name))
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestCleanDbxref(t *testing.T) {
+	in, err := os.Open(filepath.Join("testdata", "gff3", "canonical.gff3"))
+	require.NoError(t, err)
+	defer in.Close()
+	var out bytes.Buffer
+	require.NoError(t, CleanDbxref(in, &out, []string{"DDB:"}))
+	assert.Equal(t, readFixture(t, "clean_dbxref.gff3"), out.String())
+}
+
+func TestSplitPolypeptide(t *testing.T) {
+	in, err := os.Open(filepath.Join("testdata", "gff3", "canonical.gff3"))
+	require.NoError(t, err)
+	defer in.Close()
+	var genome, poly bytes.Buffer
+	require.NoError(t, SplitPolypeptide(in, &genome, &poly))
+	assert.Equal(t, readFixture(t, "no_poly.gff3"), genome.String())
+	assert.Equal(t, readFixture(t, "poly.gff3"), poly.String())
+}
+
+func TestParseSplitRule(t *testing.T) {
+	assert := assert.New(t)
+	r, err := ParseSplitRule("ncrna.gff3=so:ncRNA,type:gene,source:tRNAscan")
+	require.NoError(t, err)
+	assert.Equal(SplitRule{
+		Output:  "ncrna.gff3",
+		Types:   []string{"gene"},
+		SOTerms: []string{"ncRNA"},
+		Sources: []string{"tRNAscan"},
+	}, r)
+	for _, v := range []string{"ncrna.gff3", "=type:gene", "a.gff3=", "a.gff3=kind:gene", "a.gff3=type:"} {
+		_, err := ParseSplitRule(v)
+		assert.Error(err, v)
+	}
+}
+
+func TestSplitter(t *testing.T) {
+	in, err := os.Open(filepath.Join("testdata", "gff3", "split.gff3"))
+	require.NoError(t, err)
+	defer in.Close()
+	s := &Splitter{Rules: []SplitRule{
+		{SOTerms: []string{"ncRNA"}},
+		{Sources: []string{"GenBank"}},
+		{Types: []string{"polypeptide"}},
+	}}
+	t.Run("fasta with the default output", func(t *testing.T) {
+		var ncrna, matches, poly, rest bytes.Buffer
+		require.NoError(t, s.Split(in, []io.Writer{&ncrna, &matches, &poly}, &rest, nil))
+		header := "##gff-version 3\n##species https://www.ncbi.nlm.nih.gov/Taxonomy/Browser/wwwtax.cgi?id=44689\n"
+		assert.Equal(t, header+
+			"##sequence-region DDB0232429 1 500\n"+
+			"DDB0232429\tdictyBase\tgene\t20\t90\t.\t-\t.\tID=DDB_G0295655\n"+
+			"DDB0232429\tdictyBase\ttRNA\t20\t90\t.\t-\t.\tID=DDB0220131;Parent=DDB_G0295655\n"+
+			"DDB0232429\tdictyBase\texon\t20\t90\t.\t-\t.\tParent=DDB0220131\n"+
+			"###\n",
+			ncrna.String(), "should keep the gene and exon with the tRNA",
+		)
+		assert.Equal(t, header+
+			"##sequence-region DDB0232429 1 500\n"+
+			"DDB0232429\tGenBank\tmatch\t100\t300\t.\t+\t.\tID=AB000001\n"+
+			"DDB0232429\tGenBank\tmatch_part\t100\t300\t.\t+\t.\tParent=AB000001\n",
+			matches.String(),
+		)
+		assert.Equal(t, header+
+			"##sequence-region DDB0169550 1 100\n"+
+			"DDB0169550\tdictyBase\tpolypeptide\t1\t90\t.\t+\t.\tID=DDB0216437_poly;Derives_from=DDB0216437\n",
+			poly.String(), "should not follow Derives_from",
+		)
+		assert.Equal(t, header+
+			"##sequence-region DDB0232428 1 300\n"+
+			"# chromosome 1\n"+
+			"DDB0232428\tdictyBase\tgene\t10\t200\t.\t+\t.\tID=DDB_G0267178\n"+
+			"DDB0232428\tdictyBase\tmRNA\t10\t200\t.\t+\t.\tID=DDB0216437;Parent=DDB_G0267178\n"+
+			"DDB0232428\tdictyBase\texon\t10\t200\t.\t+\t.\tParent=DDB0216437\n"+
+			"###\n"+
+			"##FASTA\n>DDB0232428\nATGCATGCAT\n>DDB0232429\nGGCC\n",
+			rest.String(),
+		)
+	})
+	t.Run("separate fasta and shared outputs", func(t *testing.T) {
+		_, err := in.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+		var other, rest, fasta bytes.Buffer
+		require.NoError(t, s.Split(in, []io.Writer{&other, &other, &other}, &rest, &fasta))
+		assert.Equal(t, ">DDB0232428\nATGCATGCAT\n>DDB0232429\nGGCC\n", fasta.String())
+		assert.NotContains(t, rest.String(), "##FASTA")
+		assert.Contains(t, other.String(), "##sequence-region DDB0232429 1 500\n##sequence-region DDB0169550 1 100\n")
+		assert.Equal(t, 1, strings.Count(other.String(), "##gff-version"))
+	})
+}
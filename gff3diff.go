@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/migration-data-export/gff3"
+	"github.com/urfave/cli"
+)
+
+// gff3Files lists the gff3 files of a path relative to it, a file is
+// returned as it is with an empty relative name
+func gff3Files(path string) (map[string]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s", err)
+	}
+	files := make(map[string]string)
+	if !info.IsDir() {
+		files[""] = path
+		return files, nil
+	}
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(p) != ".gff3" {
+			return nil
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		files[rel] = p
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list gff3 files %s", err)
+	}
+	return files, nil
+}
+
+func sortedNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GFF3FileStats counts the features of a gff3 file
+func GFF3FileStats(path string) (*gff3.Stats, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error in opening file %s", err)
+	}
+	defer in.Close()
+	st, err := gff3.CountFeatures(in)
+	if err != nil {
+		return nil, fmt.Errorf("error in reading %s %s", path, err)
+	}
+	st.File = path
+	return st, nil
+}
+
+func GFF3StatsAction(c *cli.Context) error {
+	if !c.Args().Present() {
+		return cli.NewExitError("at least one gff3 file or folder is required", 2)
+	}
+	var all []*gff3.Stats
+	for _, path := range c.Args() {
+		files, err := gff3Files(path)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+		for _, n := range sortedNames(files) {
+			st, err := GFF3FileStats(files[n])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 2)
+			}
+			all = append(all, st)
+		}
+	}
+	out := os.Stdout
+	if len(c.String("output")) > 0 {
+		f, err := os.Create(c.String("output"))
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("error in writing file %s", err), 2)
+		}
+		defer f.Close()
+		out = f
+	}
+	if c.String("format") == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(all); err != nil {
+			return cli.NewExitError(fmt.Sprintf("error in writing stats %s", err), 2)
+		}
+		return nil
+	}
+	for i, st := range all {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		if err := st.WriteText(out); err != nil {
+			return cli.NewExitError(fmt.Sprintf("error in writing stats %s", err), 2)
+		}
+	}
+	return nil
+}
+
+// ExportDiff is the difference of two gff3 exports, either two files or
+// two folders where the files are paired by their relative path
+type ExportDiff struct {
+	Files        []*gff3.Diff `json:"files"`
+	AddedFiles   []string     `json:"added_files"`
+	RemovedFiles []string     `json:"removed_files"`
+}
+
+// Empty tells if the exports have the same files and features
+func (ed *ExportDiff) Empty() bool {
+	if len(ed.AddedFiles)+len(ed.RemovedFiles) > 0 {
+		return false
+	}
+	for _, d := range ed.Files {
+		if !d.Empty() {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteText writes the added and removed files followed by the diff
+// of every file
+func (ed *ExportDiff) WriteText(w io.Writer) error {
+	for _, f := range ed.AddedFiles {
+		if _, err := fmt.Fprintf(w, "added file %s\n", f); err != nil {
+			return err
+		}
+	}
+	for _, f := range ed.RemovedFiles {
+		if _, err := fmt.Fprintf(w, "removed file %s\n", f); err != nil {
+			return err
+		}
+	}
+	for i, d := range ed.Files {
+		if i > 0 || len(ed.AddedFiles)+len(ed.RemovedFiles) > 0 {
+			fmt.Fprintln(w)
+		}
+		if err := d.WriteText(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diffFiles(old, new string) (*gff3.Diff, error) {
+	of, err := os.Open(old)
+	if err != nil {
+		return nil, fmt.Errorf("error in opening file %s", err)
+	}
+	defer of.Close()
+	nf, err := os.Open(new)
+	if err != nil {
+		return nil, fmt.Errorf("error in opening file %s", err)
+	}
+	defer nf.Close()
+	d, err := gff3.DiffFeatures(of, nf)
+	if err != nil {
+		return nil, fmt.Errorf("error in comparing %s and %s %s", old, new, err)
+	}
+	d.Old, d.New = old, new
+	return d, nil
+}
+
+// DiffExports compares two gff3 files or the gff3 files of two folders
+func DiffExports(old, new string) (*ExportDiff, error) {
+	of, err := gff3Files(old)
+	if err != nil {
+		return nil, err
+	}
+	nf, err := gff3Files(new)
+	if err != nil {
+		return nil, err
+	}
+	_, oldFile := of[""]
+	_, newFile := nf[""]
+	if oldFile != newFile {
+		return nil, fmt.Errorf("%s and %s have to be both files or both folders", old, new)
+	}
+	ed := &ExportDiff{
+		Files:        make([]*gff3.Diff, 0),
+		AddedFiles:   make([]string, 0),
+		RemovedFiles: make([]string, 0),
+	}
+	for _, n := range sortedNames(of) {
+		if _, ok := nf[n]; !ok {
+			ed.RemovedFiles = append(ed.RemovedFiles, n)
+		}
+	}
+	for _, n := range sortedNames(nf) {
+		if _, ok := of[n]; !ok {
+			ed.AddedFiles = append(ed.AddedFiles, n)
+			continue
+		}
+		d, err := diffFiles(of[n], nf[n])
+		if err != nil {
+			return nil, err
+		}
+		ed.Files = append(ed.Files, d)
+	}
+	return ed, nil
+}
+
+func GFF3DiffAction(c *cli.Context) error {
+	if len(c.Args()) != 2 {
+		return cli.NewExitError("the old and new gff3 file or folder are required", 2)
+	}
+	ed, err := DiffExports(c.Args().Get(0), c.Args().Get(1))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	out := os.Stdout
+	if len(c.String("output")) > 0 {
+		out, err = os.Create(c.String("output"))
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("error in writing file %s", err), 2)
+		}
+		defer out.Close()
+	}
+	if c.String("format") == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(ed)
+	} else {
+		err = ed.WriteText(out)
+	}
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("error in writing diff %s", err), 2)
+	}
+	if c.Bool("exit-code") && !ed.Empty() {
+		return cli.NewExitError("", 1)
+	}
+	return nil
+}
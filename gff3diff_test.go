@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffExports(t *testing.T) {
+	old, new := t.TempDir(), t.TempDir()
+	write := func(folder, name, fixture string) {
+		path := filepath.Join(folder, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(readFixture(t, fixture)), 0644))
+	}
+	write(old, "dicty/canonical_core.gff3", "canonical.gff3")
+	write(new, "dicty/canonical_core.gff3", "clean_dbxref.gff3")
+	write(old, "dicty/canonical_mitochondrial.gff3", "poly.gff3")
+	write(new, "purpureum/canonical_core.gff3", "poly.gff3")
+	write(new, "dicty/README", "poly.gff3")
+
+	ed, err := DiffExports(old, new)
+	require.NoError(t, err)
+	assert := assert.New(t)
+	assert.Equal([]string{"purpureum/canonical_core.gff3"}, ed.AddedFiles)
+	assert.Equal([]string{"dicty/canonical_mitochondrial.gff3"}, ed.RemovedFiles)
+	require.Len(t, ed.Files, 1)
+	d := ed.Files[0]
+	assert.Equal(filepath.Join(new, "dicty/canonical_core.gff3"), d.New)
+	assert.Empty(d.Added)
+	assert.Empty(d.Removed)
+	assert.NotEmpty(d.Changed, "should report the removed dbxrefs")
+	for _, c := range d.Changed {
+		for _, fc := range c.Changes {
+			assert.Equal("attribute Dbxref", fc.Field)
+		}
+	}
+	assert.False(ed.Empty())
+
+	_, err = DiffExports(old, filepath.Join(new, "dicty/canonical_core.gff3"))
+	assert.Error(err, "should not compare a folder with a file")
+
+	same, err := DiffExports(filepath.Join(old, "dicty/canonical_core.gff3"), filepath.Join(old, "dicty/canonical_core.gff3"))
+	require.NoError(t, err)
+	assert.True(same.Empty())
+}
+
+func TestGFF3FileStats(t *testing.T) {
+	st, err := GFF3FileStats(filepath.Join("testdata", "gff3", "canonical.gff3"))
+	require.NoError(t, err)
+	assert.Equal(t, 6, st.Features)
+	assert.Equal(t, 2, st.Types["polypeptide"])
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// InventoryRecord is a stored stock of a strain or plasmid, plasmids have
+// no number of vials
+type InventoryRecord struct {
+	ID             string     `json:"id"`
+	Location       string     `json:"location"`
+	Vials          *int64     `json:"vials,omitempty"`
+	Color          string     `json:"color"`
+	StoredAs       string     `json:"stored_as"`
+	StorageDate    *time.Time `json:"storage_date,omitempty"`
+	PrivateComment string     `json:"private_comment"`
+	PublicComment  string     `json:"public_comment"`
+}
+
+var inventoryHeader = []string{
+	"id", "location", "vials", "color", "stored_as",
+	"storage_date", "private_comment", "public_comment",
+}
+
+func (r *InventoryRecord) row() []string {
+	var vials, date string
+	if r.Vials != nil {
+		vials = strconv.FormatInt(*r.Vials, 10)
+	}
+	if r.StorageDate != nil {
+		date = r.StorageDate.Format(layout)
+	}
+	return []string{
+		r.ID, r.Location, vials, r.Color, r.StoredAs,
+		date, r.PrivateComment, r.PublicComment,
+	}
+}
+
+// ReadInventory passes every record of the inventory query to the function,
+// the query returns the columns of the inventory header in their order,
+// leaving out the vials for an inventory that has none
+func ReadInventory(ctx context.Context, dbh *sql.DB, query string, hasVials bool, fn func(*InventoryRecord) error) error {
+	rows, err := dbh.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("unable to run query %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			location, color, storedAs, private, public sql.NullString
+			vials                                      sql.NullInt64
+			date                                       sql.NullTime
+		)
+		r := new(InventoryRecord)
+		dest := []interface{}{&r.ID, &location}
+		if hasVials {
+			dest = append(dest, &vials)
+		}
+		dest = append(dest, &color, &storedAs, &date, &private, &public)
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("unable to scan the next row %s", err)
+		}
+		r.Location, r.Color, r.StoredAs = location.String, color.String, storedAs.String
+		r.PrivateComment, r.PublicComment = private.String, public.String
+		if vials.Valid {
+			r.Vials = &vials.Int64
+		}
+		if date.Valid {
+			r.StorageDate = &date.Time
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("unable to close the rows %s", err)
+	}
+	return nil
+}
+
+// InventoryWriter writes the inventory records in one of the output formats
+type InventoryWriter interface {
+	Write(r *InventoryRecord) error
+	Close() error
+}
+
+// extensions of the inventory files by format
+var inventoryFormats = map[string]string{
+	"csv":   ".csv",
+	"jsonl": ".jsonl",
+}
+
+// InventoryOutput returns the file of an inventory in the folder
+func InventoryOutput(format, folder, name string) (string, error) {
+	ext, ok := inventoryFormats[format]
+	if !ok {
+		return "", fmt.Errorf("unknown inventory format %s", format)
+	}
+	return filepath.Join(folder, name+ext), nil
+}
+
+// NewInventoryWriter creates the file of an inventory in the folder, the
+// csv file starts with a header
+func NewInventoryWriter(format, folder, name string) (InventoryWriter, error) {
+	output, err := InventoryOutput(format, folder, name)
+	if err != nil {
+		return nil, err
+	}
+	if format == "jsonl" {
+		f, err := os.Create(output)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open file %s", err)
+		}
+		return &jsonInventoryWriter{f: f, enc: json.NewEncoder(f)}, nil
+	}
+	f, err := createCSVFile(output)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Write(inventoryHeader); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to write csv header %s", err)
+	}
+	return &csvInventoryWriter{f}, nil
+}
+
+type csvInventoryWriter struct {
+	*csvFile
+}
+
+func (w *csvInventoryWriter) Write(r *InventoryRecord) error {
+	return w.csvFile.Write(r.row())
+}
+
+type jsonInventoryWriter struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+func (w *jsonInventoryWriter) Write(r *InventoryRecord) error {
+	return w.enc.Encode(r)
+}
+
+func (w *jsonInventoryWriter) Close() error {
+	return w.f.Close()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadInventory(t *testing.T) {
+	stored := time.Date(2009, 1, 2, 0, 0, 0, 0, time.UTC)
+	vials := int64(3)
+	fdb := newFixtureDB()
+	fdb.table(strainInventory, inventoryHeader, [][]driver.Value{
+		{"DBS0235559", "2-9(55-57)", vials, "blue", "axenic cells", stored, "no vials left at 2-8", nil},
+	})
+	fdb.table(
+		plasmidInventory,
+		[]string{"id", "location", "color", "stored_as", "storage_date", "private_comment", "public_comment"},
+		[][]driver.Value{{"DBP0000027", "p1F1", "red", "DNA", nil, nil, "ampicillin resistant"}},
+	)
+	dbh := openFixtureDB(t, fdb)
+	read := func(query string, hasVials bool) []*InventoryRecord {
+		var records []*InventoryRecord
+		err := ReadInventory(context.Background(), dbh, query, hasVials, func(r *InventoryRecord) error {
+			records = append(records, r)
+			return nil
+		})
+		require.NoError(t, err)
+		return records
+	}
+	assert.Equal(t, []*InventoryRecord{{
+		ID: "DBS0235559", Location: "2-9(55-57)", Vials: &vials, Color: "blue", StoredAs: "axenic cells",
+		StorageDate: &stored, PrivateComment: "no vials left at 2-8",
+	}}, read(strainInventory, true))
+	assert.Equal(t, []*InventoryRecord{{
+		ID: "DBP0000027", Location: "p1F1", Color: "red", StoredAs: "DNA", PublicComment: "ampicillin resistant",
+	}}, read(plasmidInventory, false), "should read an inventory without vials")
+}
+
+func TestInventoryWriter(t *testing.T) {
+	vials := int64(3)
+	r := &InventoryRecord{ID: "DBS0235559", Location: "2-9(55-57)", Vials: &vials, Color: "blue"}
+	dir := t.TempDir()
+	for format, want := range map[string]string{
+		"csv": "id,location,vials,color,stored_as,storage_date,private_comment,public_comment\n" +
+			"DBS0235559,2-9(55-57),3,blue,,,,\n",
+		"jsonl": `{"id":"DBS0235559","location":"2-9(55-57)","vials":3,"color":"blue","stored_as":"","private_comment":"","public_comment":""}` + "\n",
+	} {
+		w, err := NewInventoryWriter(format, dir, "strain_stocks")
+		require.NoError(t, err)
+		require.NoError(t, w.Write(r))
+		require.NoError(t, w.Close())
+		output, err := InventoryOutput(format, dir, "strain_stocks")
+		require.NoError(t, err)
+		b, err := ioutil.ReadFile(output)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(b), format)
+	}
+	_, err := NewInventoryWriter("xml", dir, "strain_stocks")
+	assert.Error(t, err, "should not accept an unknown format")
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/migration-data-export/runner"
+	"github.com/urfave/cli"
+)
+
+// jobStateFile is the name of the file that keeps the state of the jobs
+// of a command, every command sharing the folder gets its own
+func jobStateFile(command string) string {
+	return fmt.Sprintf("jobstate.%s.json", command)
+}
+
+// JobState is the last known state of a job along with the checksum of
+// every file it produced and read
+type JobState struct {
+	Name       string            `json:"name"`
+	Subcommand string            `json:"subcommand"`
+	Status     string            `json:"status"`
+	Updated    time.Time         `json:"updated"`
+	Error      string            `json:"error,omitempty"`
+	Outputs    map[string]string `json:"outputs"`
+	Inputs     map[string]string `json:"inputs,omitempty"`
+}
+
+// StateStore keeps the state of jobs in a json file
+type StateStore struct {
+	mu   sync.Mutex
+	path string
+	Jobs map[string]*JobState `json:"jobs"`
+}
+
+// stateFolder is the folder of the state file of a command, the config
+// folder if the command has one or else the output folder
+func stateFolder(c *cli.Context) string {
+	if len(c.String("config-folder")) > 0 {
+		return c.String("config-folder")
+	}
+	return c.String("output-folder")
+}
+
+// OpenStateStore reads the state file of the command in the folder, an
+// empty store is returned if the file does not exist yet
+func OpenStateStore(folder, command string) (*StateStore, error) {
+	st := &StateStore{
+		path: filepath.Join(folder, jobStateFile(command)),
+		Jobs: make(map[string]*JobState),
+	}
+	b, err := ioutil.ReadFile(st.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read job state %s", err)
+	}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, fmt.Errorf("unable to decode job state %s", err)
+	}
+	if st.Jobs == nil {
+		st.Jobs = make(map[string]*JobState)
+	}
+	return st, nil
+}
+
+// Record saves the result of a job along with the checksum of its outputs
+// and inputs, files of an output folder are recorded if they are written
+// after the job started
+func (st *StateStore) Record(j runner.Job, res runner.Result) error {
+	if res.Status == runner.Skipped {
+		// keeps the state of the earlier run
+		return nil
+	}
+	js := &JobState{
+		Name:       j.Name,
+		Subcommand: j.Subcommand,
+		Status:     string(res.Status),
+		Updated:    time.Now(),
+		Outputs:    make(map[string]string),
+		Inputs:     make(map[string]string),
+	}
+	if res.Err != nil {
+		js.Error = redactor.Redact(res.Err.Error())
+	}
+	if res.Status == runner.Completed {
+		for _, o := range j.Outputs {
+			if err := checksumOutput(o, res.Start, js.Outputs); err != nil {
+				return err
+			}
+		}
+		for _, in := range j.Inputs {
+			if err := checksumOutput(in, time.Time{}, js.Inputs); err != nil {
+				return err
+			}
+		}
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.Jobs[j.Name] = js
+	return st.save()
+}
+
+func checksumOutput(output string, since time.Time, sums map[string]string) error {
+	return filepath.Walk(output, func(path string, info os.FileInfo, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || (path != output && info.ModTime().Before(since)) {
+			return nil
+		}
+		fs, err := StatFile(path)
+		if err != nil {
+			return err
+		}
+		sums[path] = fs.Sha256
+		return nil
+	})
+}
+
+func (st *StateStore) save() error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode job state %s", err)
+	}
+	return writeFileAtomic(st.path, b)
+}
+
+// writeFileAtomic writes the file through a uniquely named temporary file
+// in the same folder, so readers never see a partial file
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file %s", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write %s %s", path, err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write %s %s", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write %s %s", path, err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Check compares the recorded outputs and inputs of a job with the files
+// on disk and returns a problem if the job has to be run again
+func (st *StateStore) Check(name string) error {
+	st.mu.Lock()
+	js, ok := st.Jobs[name]
+	st.mu.Unlock()
+	switch {
+	case !ok:
+		return fmt.Errorf("never run")
+	case js.Status != string(runner.Completed):
+		return fmt.Errorf("last run %s", js.Status)
+	case len(js.Outputs) == 0:
+		return fmt.Errorf("no recorded output")
+	}
+	if err := compareChecksums(js.Outputs); err != nil {
+		return err
+	}
+	return compareChecksums(js.Inputs)
+}
+
+func compareChecksums(sums map[string]string) error {
+	for path, sum := range sums {
+		fs, err := StatFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("missing %s", path)
+		}
+		if err != nil {
+			return err
+		}
+		if fs.Sha256 != sum {
+			return fmt.Errorf("changed %s", path)
+		}
+	}
+	return nil
+}
+
+// Resumable tells if a completed job could be skipped as all of its
+// outputs and inputs are unchanged
+func (st *StateStore) Resumable(j runner.Job) bool {
+	return st.Check(j.Name) == nil
+}
+
+// stateCommands returns the commands that have a state file in the folder
+func stateCommands(folder string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(folder, jobStateFile("*")))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list job states %s", err)
+	}
+	commands := make([]string, 0)
+	for _, p := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(p), "jobstate."), ".json")
+		commands = append(commands, name)
+	}
+	return commands, nil
+}
+
+func StatusAction(c *cli.Context) error {
+	if !c.IsSet("config-folder") {
+		return cli.NewExitError("argument config-folder is required", 2)
+	}
+	folder := c.String("config-folder")
+	commands := []string{c.String("command")}
+	if !c.IsSet("command") {
+		var err error
+		commands, err = stateCommands(folder)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	var count int
+	for _, command := range commands {
+		st, err := OpenStateStore(folder, command)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+		names := make([]string, 0)
+		for n := range st.Jobs {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			if count == 0 {
+				fmt.Fprintln(w, "command\tjob\tsubcommand\tstatus\tupdated\toutputs\tresumable")
+			}
+			count++
+			js := st.Jobs[n]
+			resumable := "yes"
+			if err := st.Check(n); err != nil {
+				resumable = fmt.Sprintf("no, %s", err)
+			}
+			fmt.Fprintf(
+				w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+				command, js.Name, js.Subcommand, js.Status,
+				js.Updated.Format(layout), len(js.Outputs), resumable,
+			)
+		}
+	}
+	if count == 0 {
+		fmt.Printf("no job state found in %s\n", folder)
+		return nil
+	}
+	return w.Flush()
+}
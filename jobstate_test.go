@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/migration-data-export/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateStore(t *testing.T) {
+	folder := t.TempDir()
+	output := filepath.Join(folder, "dictystrain.csv")
+	require.NoError(t, ioutil.WriteFile(output, []byte("id\nDBS0236123\n"), 0644))
+	job := runner.Job{Name: "dictystrain", Subcommand: "dictystrain", Outputs: []string{output}}
+	now := time.Now()
+	st, err := OpenStateStore(folder, "dsc-annotations")
+	require.NoError(t, err)
+
+	t.Run("never run", func(t *testing.T) {
+		assert.EqualError(t, st.Check("dictystrain"), "never run")
+		assert.False(t, st.Resumable(job))
+	})
+	t.Run("failed", func(t *testing.T) {
+		res := runner.Result{Name: job.Name, Status: runner.Failed, Err: fmt.Errorf("exit status 1"), Start: now, End: now}
+		require.NoError(t, st.Record(job, res))
+		assert.EqualError(t, st.Check("dictystrain"), "last run failed")
+		assert.False(t, st.Resumable(job))
+	})
+	t.Run("completed", func(t *testing.T) {
+		res := runner.Result{Name: job.Name, Status: runner.Completed, Start: now, End: now}
+		require.NoError(t, st.Record(job, res))
+		assert.NoError(t, st.Check("dictystrain"))
+		assert.True(t, st.Resumable(job))
+		reopened, err := OpenStateStore(folder, "dsc-annotations")
+		require.NoError(t, err)
+		assert.True(t, reopened.Resumable(job), "should read back the recorded state")
+	})
+	t.Run("skipped keeps the state", func(t *testing.T) {
+		res := runner.Result{Name: job.Name, Status: runner.Skipped, Start: now, End: now}
+		require.NoError(t, st.Record(job, res))
+		assert.True(t, st.Resumable(job))
+	})
+	t.Run("changed output", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(output, []byte("id\n"), 0644))
+		assert.EqualError(t, st.Check("dictystrain"), fmt.Sprintf("changed %s", output))
+		assert.False(t, st.Resumable(job))
+	})
+	t.Run("missing output", func(t *testing.T) {
+		require.NoError(t, os.Remove(output))
+		assert.EqualError(t, st.Check("dictystrain"), fmt.Sprintf("missing %s", output))
+		assert.False(t, st.Resumable(job))
+	})
+	t.Run("no recorded output", func(t *testing.T) {
+		res := runner.Result{Name: job.Name, Status: runner.Completed, Start: now, End: now}
+		require.NoError(t, st.Record(job, res))
+		assert.EqualError(t, st.Check("dictystrain"), "no recorded output")
+	})
+	t.Run("changed input", func(t *testing.T) {
+		input := filepath.Join(folder, "canonical.gff3")
+		require.NoError(t, ioutil.WriteFile(input, []byte("##gff-version 3\n"), 0644))
+		report := filepath.Join(folder, "canonical.validation.json")
+		require.NoError(t, ioutil.WriteFile(report, []byte("{}\n"), 0644))
+		vjob := runner.Job{Name: "validate_canonical", Outputs: []string{report}, Inputs: []string{input}}
+		res := runner.Result{Name: vjob.Name, Status: runner.Completed, Start: now, End: now}
+		require.NoError(t, st.Record(vjob, res))
+		assert.True(t, st.Resumable(vjob))
+		require.NoError(t, ioutil.WriteFile(input, []byte("##gff-version 3\n##sequence-region\n"), 0644))
+		assert.EqualError(t, st.Check(vjob.Name), fmt.Sprintf("changed %s", input))
+		assert.False(t, st.Resumable(vjob), "should validate a changed gff3 again")
+	})
+}
+
+func TestStateStoreCommands(t *testing.T) {
+	assert := assert.New(t)
+	folder := t.TempDir()
+	now := time.Now()
+	commands := []string{"dsc-orders", "dsc-users", "dsc-inventory"}
+	var wg sync.WaitGroup
+	for _, command := range commands {
+		st, err := OpenStateStore(folder, command)
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(st *StateStore, name string) {
+				defer wg.Done()
+				res := runner.Result{Name: name, Status: runner.Failed, Start: now, End: now}
+				assert.NoError(st.Record(runner.Job{Name: name}, res))
+			}(st, fmt.Sprintf("%s-%d", command, i))
+		}
+	}
+	wg.Wait()
+	found, err := stateCommands(folder)
+	require.NoError(t, err)
+	assert.ElementsMatch(commands, found)
+	for _, command := range commands {
+		st, err := OpenStateStore(folder, command)
+		require.NoError(t, err)
+		assert.Len(st.Jobs, 10, "should keep the jobs of every command")
+	}
+	tmp, err := filepath.Glob(filepath.Join(folder, "*.tmp"))
+	require.NoError(t, err)
+	assert.Empty(tmp, "should not leave temporary files behind")
+}
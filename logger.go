@@ -38,6 +38,8 @@ func getLogger(c *cli.Context) *logrus.Logger {
 	}
 	// Set up hook
 	lh := make(logrus.LevelHooks)
+	// runs before any other hook
+	lh.Add(redactor)
 	for _, h := range c.GlobalStringSlice("hooks") {
 		switch h {
 		case "slack":
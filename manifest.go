@@ -0,0 +1,174 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v1"
+)
+
+// default manifests that are used when no manifest file is given
+//
+//go:embed manifests/*.yml
+var defaultManifests embed.FS
+
+// config templates that a manifest job could refer to
+var configTemplates = map[string]func(*cli.Context, string, string) string{
+	"custom": MakeCustomConfigFile,
+	"dicty":  MakeDictyConfigFile,
+}
+
+// Manifest describes a list of modware export jobs
+type Manifest struct {
+	Jobs []ManifestJob `yaml:"jobs" json:"jobs"`
+}
+
+// ManifestJob describes a single run of modware-export. The generated
+// config file and the output file are named after the job.
+type ManifestJob struct {
+	Name      string            `yaml:"name" json:"name"`
+	Command   string            `yaml:"command" json:"command"`
+	Template  string            `yaml:"template" json:"template"`
+	Subfolder string            `yaml:"subfolder" json:"subfolder"`
+	Options   map[string]string `yaml:"options" json:"options"`
+}
+
+// ParseManifest parses a manifest, json is expected when the format
+// is json, otherwise yaml
+func ParseManifest(b []byte, format string) (*Manifest, error) {
+	m := new(Manifest)
+	if format == "json" {
+		if err := json.Unmarshal(b, m); err != nil {
+			return m, fmt.Errorf("unable to decode json manifest %s", err)
+		}
+		return m, nil
+	}
+	if err := yaml.Unmarshal(b, m); err != nil {
+		return m, fmt.Errorf("unable to decode yaml manifest %s", err)
+	}
+	return m, nil
+}
+
+// LoadManifest reads a manifest file, the format is guessed from the
+// file extension
+func LoadManifest(path string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest %s", err)
+	}
+	format := "yaml"
+	if filepath.Ext(path) == ".json" {
+		format = "json"
+	}
+	return ParseManifest(b, format)
+}
+
+// LoadCommandManifest loads the manifest given in the command line or
+// falls back to the default manifest of the command
+func LoadCommandManifest(c *cli.Context) (*Manifest, error) {
+	if len(c.String("manifest")) > 0 {
+		return LoadManifest(c.String("manifest"))
+	}
+	b, err := defaultManifests.ReadFile(
+		fmt.Sprintf("manifests/%s.yml", c.Command.Name),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("no default manifest for %s", c.Command.Name)
+	}
+	return ParseManifest(b, "yaml")
+}
+
+// Validate checks the manifest and returns all the problems that are found
+func (m *Manifest) Validate() []error {
+	var errs []error
+	if len(m.Jobs) == 0 {
+		errs = append(errs, fmt.Errorf("manifest has no jobs"))
+	}
+	seen := make(map[string]bool)
+	for i, j := range m.Jobs {
+		if len(j.Name) == 0 {
+			errs = append(errs, fmt.Errorf("job %d has no name", i+1))
+		} else if seen[j.Name] {
+			errs = append(errs, fmt.Errorf("job %s is defined more than once", j.Name))
+		}
+		seen[j.Name] = true
+		if len(j.Command) == 0 || strings.ContainsAny(j.Command, " \t") {
+			errs = append(errs, fmt.Errorf("job %s has invalid command %q", j.Name, j.Command))
+		}
+		if _, ok := configTemplates[j.Template]; !ok {
+			errs = append(errs, fmt.Errorf("job %s has unknown template %q", j.Name, j.Template))
+		}
+		if len(j.Subfolder) == 0 || filepath.IsAbs(j.Subfolder) ||
+			strings.HasPrefix(filepath.Clean(j.Subfolder), "..") {
+			errs = append(errs, fmt.Errorf("job %s has invalid subfolder %q", j.Name, j.Subfolder))
+		}
+		if _, ok := j.Options["config"]; ok {
+			errs = append(errs, fmt.Errorf("job %s sets the reserved option config", j.Name))
+		}
+	}
+	return errs
+}
+
+// UsesTemplate checks if any job of the manifest needs the given config template
+func (m *Manifest) UsesTemplate(name string) bool {
+	for _, j := range m.Jobs {
+		if j.Template == name {
+			return true
+		}
+	}
+	return false
+}
+
+// MakeJobOptions generates the config file of the job and returns the
+// options for running it, the config comes first followed by the options
+// of the manifest sorted by name
+func MakeJobOptions(c *cli.Context, j ManifestJob) Options {
+	conf := configTemplates[j.Template](c, j.Name, j.Subfolder)
+	CreateFolderFromYaml(conf)
+	return append(Options{{"config", conf}}, OptionsFromMap(j.Options)...)
+}
+
+func validateManifest(m *Manifest, name string) error {
+	if errs := m.Validate(); len(errs) > 0 {
+		msg := make([]string, 0)
+		for _, err := range errs {
+			msg = append(msg, err.Error())
+		}
+		return fmt.Errorf("invalid manifest %s\n%s", name, strings.Join(msg, "\n"))
+	}
+	return nil
+}
+
+func ValidateManifestAction(c *cli.Context) error {
+	if !c.IsSet("manifest") {
+		for _, name := range []string{"canonicalgff3", "extradictygff3"} {
+			b, err := defaultManifests.ReadFile(fmt.Sprintf("manifests/%s.yml", name))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 2)
+			}
+			m, err := ParseManifest(b, "yaml")
+			if err != nil {
+				return cli.NewExitError(err.Error(), 2)
+			}
+			if err := validateManifest(m, name); err != nil {
+				return cli.NewExitError(err.Error(), 2)
+			}
+			fmt.Printf("default manifest of %s is valid with %d jobs\n", name, len(m.Jobs))
+		}
+		return nil
+	}
+	m, err := LoadManifest(c.String("manifest"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	if err := validateManifest(m, c.String("manifest")); err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	fmt.Printf("manifest %s is valid with %d jobs\n", c.String("manifest"), len(m.Jobs))
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+func manifestContext(t *testing.T, command, manifest string) *cli.Context {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("manifest", "", "")
+	if len(manifest) > 0 {
+		require.NoError(t, set.Set("manifest", manifest))
+	}
+	c := cli.NewContext(nil, set, nil)
+	c.Command = cli.Command{Name: command}
+	return c
+}
+
+func TestLoadCommandManifest(t *testing.T) {
+	for _, command := range []string{"canonicalgff3", "extradictygff3"} {
+		command := command
+		t.Run(command, func(t *testing.T) {
+			m, err := LoadCommandManifest(manifestContext(t, command, ""))
+			require.NoError(t, err)
+			assert.NotEmpty(t, m.Jobs, "should have jobs in the embedded manifest")
+			assert.Empty(t, m.Validate(), "should be a valid embedded manifest")
+		})
+	}
+	t.Run("unknown command", func(t *testing.T) {
+		_, err := LoadCommandManifest(manifestContext(t, "nogff3", ""))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no default manifest for nogff3")
+	})
+}
+
+func TestValidateManifestAction(t *testing.T) {
+	cases := []struct {
+		name     string
+		manifest string
+		err      string
+	}{
+		{
+			name: "valid",
+			manifest: `jobs:
+  - name: core
+    command: chado2canonicalgff3
+    template: custom
+    subfolder: purpureum
+`,
+		},
+		{
+			name: "missing template",
+			manifest: `jobs:
+  - name: core
+    command: chado2canonicalgff3
+    subfolder: purpureum
+`,
+			err: `job core has unknown template ""`,
+		},
+		{
+			name: "unknown template",
+			manifest: `jobs:
+  - name: core
+    command: chado2canonicalgff3
+    template: plain
+    subfolder: purpureum
+`,
+			err: `job core has unknown template "plain"`,
+		},
+		{
+			name: "missing command",
+			manifest: `jobs:
+  - name: core
+    template: custom
+    subfolder: purpureum
+`,
+			err: `job core has invalid command ""`,
+		},
+		{
+			name: "duplicate job names",
+			manifest: `jobs:
+  - name: core
+    command: chado2canonicalgff3
+    template: custom
+    subfolder: purpureum
+  - name: core
+    command: chado2canonicalgff3
+    template: custom
+    subfolder: pallidum
+`,
+			err: "job core is defined more than once",
+		},
+		{
+			name:     "no jobs",
+			manifest: "jobs: []\n",
+			err:      "manifest has no jobs",
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "manifest.yml")
+			require.NoError(t, ioutil.WriteFile(path, []byte(tc.manifest), 0644))
+			err := ValidateManifestAction(manifestContext(t, "validate-manifest", path))
+			if len(tc.err) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+	t.Run("default manifests", func(t *testing.T) {
+		assert.NoError(t, ValidateManifestAction(manifestContext(t, "validate-manifest", "")))
+	})
+}
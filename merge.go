@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/migration-data-export/gff3"
+	"github.com/urfave/cli"
+)
+
+// default number of features that are sorted in memory
+const defaultChunkSize = 100000
+
+// mergeKey orders the features of the merged file, the features of a
+// family share the seqid, start, input and group of the family so that
+// they stay together in their original order
+type mergeKey struct {
+	seqid string
+	start int
+	input int
+	group int
+	line  int
+}
+
+func (k mergeKey) less(o mergeKey) bool {
+	switch {
+	case k.seqid != o.seqid:
+		return k.seqid < o.seqid
+	case k.start != o.start:
+		return k.start < o.start
+	case k.input != o.input:
+		return k.input < o.input
+	case k.group != o.group:
+		return k.group < o.group
+	}
+	return k.line < o.line
+}
+
+// mergeRecord is a sort key with its feature line, it is kept in the
+// temporary files as a tab separated line
+type mergeRecord struct {
+	mergeKey
+	text string
+}
+
+func (r mergeRecord) String() string {
+	return fmt.Sprintf("%s\t%d\t%d\t%d\t%d\t%s", r.seqid, r.start, r.input, r.group, r.line, r.text)
+}
+
+func parseMergeRecord(line string) (mergeRecord, error) {
+	var r mergeRecord
+	f := strings.SplitN(line, "\t", 6)
+	if len(f) != 6 {
+		return r, fmt.Errorf("invalid sort record %q", line)
+	}
+	r.seqid, r.text = f[0], f[5]
+	for i, p := range []*int{&r.start, &r.input, &r.group, &r.line} {
+		v, err := strconv.Atoi(f[i+1])
+		if err != nil {
+			return r, fmt.Errorf("invalid sort record %q %s", line, err)
+		}
+		*p = v
+	}
+	return r, nil
+}
+
+// mergeGroup is the position of a family, the seqid of its first feature
+// and its lowest start
+type mergeGroup struct {
+	seqid string
+	start int
+	line  int
+}
+
+// mergeRegion is the widest range of a sequence region of all the inputs
+type mergeRegion struct {
+	start int
+	end   int
+}
+
+// Merger merges gff3 files into a single file sorted by seqid and start.
+// The features are sorted in chunks that are written to temporary files
+// and merged at the end, only the IDs of the features are kept in memory.
+type Merger struct {
+	ChunkSize int
+	TempDir   string
+	version   string
+	header    []*gff3.Directive
+	seen      map[string]bool
+	regions   map[string]*mergeRegion
+	sequences map[string][sha256.Size]byte
+	fasta     *gff3.Writer
+	chunk     []mergeRecord
+	runs      []string
+	tmp       string
+}
+
+// Merge writes the merged inputs to the output and returns the number
+// of features. Directives are written once, the sequence regions are
+// widened to cover all the inputs and the sequences of the same ID
+// have to be identical.
+func (m *Merger) Merge(inputs []string, out io.Writer) (int, error) {
+	if m.ChunkSize <= 0 {
+		m.ChunkSize = defaultChunkSize
+	}
+	m.version = "3"
+	m.header = nil
+	m.seen = make(map[string]bool)
+	m.regions = make(map[string]*mergeRegion)
+	m.sequences = make(map[string][sha256.Size]byte)
+	m.chunk = nil
+	m.runs = nil
+	tmp, err := ioutil.TempDir(m.TempDir, "merge-gff3")
+	if err != nil {
+		return 0, fmt.Errorf("unable to create temporary folder %s", err)
+	}
+	m.tmp = tmp
+	defer os.RemoveAll(tmp)
+	fa, err := os.Create(filepath.Join(tmp, "sequences.fa"))
+	if err != nil {
+		return 0, fmt.Errorf("unable to create temporary file %s", err)
+	}
+	defer fa.Close()
+	m.fasta = gff3.NewFastaWriter(fa)
+	for i, in := range inputs {
+		if err := m.add(i, in); err != nil {
+			return 0, fmt.Errorf("error in merging %s %s", in, err)
+		}
+	}
+	if err := m.flushChunk(); err != nil {
+		return 0, err
+	}
+	if err := m.fasta.Flush(); err != nil {
+		return 0, fmt.Errorf("unable to write sequences %s", err)
+	}
+	w := bufio.NewWriter(out)
+	if err := m.writeHeader(w); err != nil {
+		return 0, err
+	}
+	count, err := m.writeFeatures(w)
+	if err != nil {
+		return 0, err
+	}
+	if len(m.sequences) > 0 {
+		if _, err := fa.Seek(0, io.SeekStart); err != nil {
+			return 0, fmt.Errorf("unable to rewind sequences %s", err)
+		}
+		if _, err := fmt.Fprintln(w, "##FASTA"); err != nil {
+			return 0, err
+		}
+		if _, err := io.Copy(w, fa); err != nil {
+			return 0, fmt.Errorf("unable to copy sequences %s", err)
+		}
+	}
+	return count, w.Flush()
+}
+
+// add reads an input twice, first to find its families and then to sort
+// its features
+func (m *Merger) add(input int, path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error in opening file %s", err)
+	}
+	defer in.Close()
+	fm := make(families)
+	groups := make(map[string]*mergeGroup)
+	line := 0
+	err = transformGFF3(in, func(rec *gff3.Record) error {
+		switch rec.Kind {
+		case gff3.DirectiveKind:
+			return m.addDirective(rec.Directive)
+		case gff3.SequenceKind:
+			return m.addSequence(rec.Sequence)
+		case gff3.FeatureKind:
+			line++
+			f := rec.Feature
+			key := familyKey(f)
+			if len(key) == 0 {
+				return nil
+			}
+			fm.find(key)
+			for _, p := range f.Parents() {
+				fm.union(p, key)
+			}
+			if g, ok := groups[key]; !ok {
+				groups[key] = &mergeGroup{seqid: f.SeqID, start: f.Start, line: line}
+			} else if f.Start < g.start {
+				g.start = f.Start
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// the position of a family is taken from its earliest member
+	pos := make(map[string]*mergeGroup)
+	for key, g := range groups {
+		root := fm.find(key)
+		fg, ok := pos[root]
+		if !ok {
+			pos[root] = &mergeGroup{seqid: g.seqid, start: g.start, line: g.line}
+			continue
+		}
+		if g.line < fg.line {
+			fg.seqid, fg.line = g.seqid, g.line
+		}
+		if g.start < fg.start {
+			fg.start = g.start
+		}
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to rewind input %s", err)
+	}
+	line = 0
+	return transformGFF3(in, func(rec *gff3.Record) error {
+		if rec.Kind != gff3.FeatureKind {
+			return nil
+		}
+		line++
+		f := rec.Feature
+		r := mergeRecord{
+			mergeKey: mergeKey{seqid: f.SeqID, start: f.Start, input: input, group: line, line: line},
+			text:     f.String(),
+		}
+		if key := familyKey(f); len(key) > 0 {
+			g := pos[fm.find(key)]
+			r.seqid, r.start, r.group = g.seqid, g.start, g.line
+		}
+		m.chunk = append(m.chunk, r)
+		if len(m.chunk) >= m.ChunkSize {
+			return m.flushChunk()
+		}
+		return nil
+	})
+}
+
+func (m *Merger) addDirective(d *gff3.Directive) error {
+	switch d.Name {
+	case "gff-version":
+		m.version = d.Value
+	case "sequence-region":
+		seqid, start, end, err := d.SequenceRegion()
+		if err != nil {
+			return err
+		}
+		r, ok := m.regions[seqid]
+		if !ok {
+			m.regions[seqid] = &mergeRegion{start: start, end: end}
+			return nil
+		}
+		if start < r.start {
+			r.start = start
+		}
+		if end > r.end {
+			r.end = end
+		}
+	case "FASTA", "#":
+	default:
+		if !m.seen[d.String()] {
+			m.seen[d.String()] = true
+			m.header = append(m.header, d)
+		}
+	}
+	return nil
+}
+
+func (m *Merger) addSequence(s *gff3.Sequence) error {
+	sum := sha256.Sum256([]byte(s.Residues))
+	if prev, ok := m.sequences[s.ID]; ok {
+		if prev != sum {
+			return fmt.Errorf("sequence %s differs from an earlier input", s.ID)
+		}
+		return nil
+	}
+	m.sequences[s.ID] = sum
+	return m.fasta.WriteSequence(s)
+}
+
+// flushChunk sorts the features in memory and writes them to a
+// temporary file
+func (m *Merger) flushChunk() error {
+	if len(m.chunk) == 0 {
+		return nil
+	}
+	sort.Slice(m.chunk, func(i, j int) bool { return m.chunk[i].less(m.chunk[j].mergeKey) })
+	name := filepath.Join(m.tmp, fmt.Sprintf("run%d", len(m.runs)))
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file %s", err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for _, r := range m.chunk {
+		if _, err := fmt.Fprintln(w, r.String()); err != nil {
+			return fmt.Errorf("unable to write temporary file %s", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("unable to write temporary file %s", err)
+	}
+	m.runs = append(m.runs, name)
+	m.chunk = m.chunk[:0]
+	return nil
+}
+
+func (m *Merger) writeHeader(w io.Writer) error {
+	dirs := []*gff3.Directive{{Name: "gff-version", Value: m.version}}
+	dirs = append(dirs, m.header...)
+	seqids := make([]string, 0, len(m.regions))
+	for id := range m.regions {
+		seqids = append(seqids, id)
+	}
+	sort.Strings(seqids)
+	for _, id := range seqids {
+		r := m.regions[id]
+		dirs = append(dirs, &gff3.Directive{
+			Name:  "sequence-region",
+			Value: fmt.Sprintf("%s %d %d", id, r.start, r.end),
+		})
+	}
+	for _, d := range dirs {
+		if _, err := fmt.Fprintln(w, d.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeRun is the next record of a sorted temporary file
+type mergeRun struct {
+	s   *bufio.Scanner
+	rec mergeRecord
+}
+
+type mergeHeap []*mergeRun
+
+func (h mergeHeap) Len() int            { return len(h) }
+func (h mergeHeap) Less(i, j int) bool  { return h[i].rec.less(h[j].rec.mergeKey) }
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(*mergeRun)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// next reads the next record of the run, false is returned at the end
+func (r *mergeRun) next() (bool, error) {
+	if !r.s.Scan() {
+		return false, r.s.Err()
+	}
+	rec, err := parseMergeRecord(r.s.Text())
+	if err != nil {
+		return false, err
+	}
+	r.rec = rec
+	return true, nil
+}
+
+// writeFeatures merges the sorted temporary files
+func (m *Merger) writeFeatures(w io.Writer) (int, error) {
+	h := make(mergeHeap, 0, len(m.runs))
+	for _, name := range m.runs {
+		f, err := os.Open(name)
+		if err != nil {
+			return 0, fmt.Errorf("unable to open temporary file %s", err)
+		}
+		defer f.Close()
+		s := bufio.NewScanner(f)
+		s.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		r := &mergeRun{s: s}
+		ok, err := r.next()
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			h = append(h, r)
+		}
+	}
+	heap.Init(&h)
+	count := 0
+	for h.Len() > 0 {
+		r := h[0]
+		if _, err := fmt.Fprintln(w, r.rec.text); err != nil {
+			return count, err
+		}
+		count++
+		ok, err := r.next()
+		if err != nil {
+			return count, err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return count, nil
+}
+
+func MergeGFF3Action(c *cli.Context) error {
+	inputs := append(c.StringSlice("input"), c.Args()...)
+	if len(inputs) == 0 {
+		return cli.NewExitError("at least one input is required", 2)
+	}
+	if !c.IsSet("output") {
+		return cli.NewExitError("argument output is required", 2)
+	}
+	for _, in := range inputs {
+		if filepath.Clean(in) == filepath.Clean(c.String("output")) {
+			return cli.NewExitError(fmt.Sprintf("output %s is also an input", in), 2)
+		}
+	}
+	out, err := os.Create(c.String("output"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("error in writing file %s", err), 2)
+	}
+	defer out.Close()
+	m := &Merger{ChunkSize: c.Int("chunk-size"), TempDir: c.String("temp-folder")}
+	count, err := m.Merge(inputs, out)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	getLogger(c).Infof("merged %d features of %d files to %s", count, len(inputs), c.String("output"))
+	return nil
+}
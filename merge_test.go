@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerger(t *testing.T) {
+	inputs := []string{
+		filepath.Join("testdata", "gff3", "merge_a.gff3"),
+		filepath.Join("testdata", "gff3", "merge_b.gff3"),
+	}
+	for _, size := range []int{0, 1, 3} {
+		t.Run(fmt.Sprintf("chunk size %d", size), func(t *testing.T) {
+			tmp := t.TempDir()
+			var out bytes.Buffer
+			m := &Merger{ChunkSize: size, TempDir: tmp}
+			count, err := m.Merge(inputs, &out)
+			require.NoError(t, err)
+			assert.Equal(t, 11, count)
+			assert.Equal(t, readFixture(t, "merged.gff3"), out.String())
+			left, err := ioutil.ReadDir(tmp)
+			require.NoError(t, err)
+			assert.Empty(t, left, "should remove the temporary files")
+		})
+	}
+	t.Run("conflicting sequences", func(t *testing.T) {
+		conflict := filepath.Join(t.TempDir(), "conflict.gff3")
+		require.NoError(t, ioutil.WriteFile(conflict, []byte("##gff-version 3\n##FASTA\n>chr1\nTTTT\n"), 0644))
+		var out bytes.Buffer
+		_, err := (&Merger{}).Merge(append(inputs, conflict), &out)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import "sort"
+
+// Option is a command line option of a modware subcommand, single letter
+// names are given with a single dash
+type Option struct {
+	Name  string
+	Value string
+}
+
+// Arg returns the option as command line arguments
+func (o Option) Arg() []string {
+	if len(o.Name) == 1 {
+		return []string{"-" + o.Name, o.Value}
+	}
+	return []string{"--" + o.Name, o.Value}
+}
+
+// Options is an ordered list of options, the command line arguments
+// always follow the order of the list
+type Options []Option
+
+// Args returns the subcommand followed by the options as command line arguments
+func (opts Options) Args(subcmd string) []string {
+	p := []string{subcmd}
+	for _, o := range opts {
+		p = append(p, o.Arg()...)
+	}
+	return p
+}
+
+// Get returns the value of the option
+func (opts Options) Get(name string) (string, bool) {
+	for _, o := range opts {
+		if o.Name == name {
+			return o.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set replaces the value of the option or adds it to the end of the list
+func (opts Options) Set(name, value string) Options {
+	for i, o := range opts {
+		if o.Name == name {
+			opts[i].Value = value
+			return opts
+		}
+	}
+	return append(opts, Option{Name: name, Value: value})
+}
+
+// OptionsFromMap converts a map to options sorted by name
+func OptionsFromMap(m map[string]string) Options {
+	names := make([]string, 0)
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	opts := make(Options, 0)
+	for _, k := range names {
+		opts = append(opts, Option{Name: k, Value: m[k]})
+	}
+	return opts
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions(t *testing.T) {
+	opts := Options{
+		{"dsn", "dbi:Oracle:sid"},
+		{"u", "user"},
+		{"output", "out.csv"},
+	}
+	t.Run("args", func(t *testing.T) {
+		assert.Equal(
+			t,
+			[]string{"dump", "--dsn", "dbi:Oracle:sid", "-u", "user", "--output", "out.csv"},
+			opts.Args("dump"),
+			"should keep the declared order of options",
+		)
+	})
+	t.Run("set", func(t *testing.T) {
+		o := append(Options{}, opts...).Set("u", "other").Set("dir", "/tmp")
+		v, ok := o.Get("u")
+		assert.True(t, ok)
+		assert.Equal(t, "other", v, "should replace the value in place")
+		assert.Equal(t, "dir", o[len(o)-1].Name, "should append a new option")
+	})
+	t.Run("map", func(t *testing.T) {
+		o := OptionsFromMap(map[string]string{"b": "2", "a": "1", "c": "3"})
+		assert.Equal(t, []string{"x", "-a", "1", "-b", "2", "-c", "3"}, o.Args("x"))
+	})
+}
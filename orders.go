@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kinds of the items of a stock order, an unknown item is neither a
+// plasmid nor a strain
+const (
+	PlasmidItem = "plasmid"
+	StrainItem  = "strain"
+	UnknownItem = "unknown"
+)
+
+// OrderItem is a plasmid by its name, a strain by its accession or an
+// unknown item as it was ordered
+type OrderItem struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+}
+
+// Order is a stock center order of a user
+type Order struct {
+	ID    int64       `json:"order_id"`
+	Date  time.Time   `json:"date"`
+	Email string      `json:"email"`
+	Items []OrderItem `json:"items"`
+}
+
+// ItemIDs returns the IDs of the items of a kind in their order
+func (o *Order) ItemIDs(kind string) []string {
+	ids := make([]string, 0)
+	for _, it := range o.Items {
+		if it.Kind == kind {
+			ids = append(ids, it.ID)
+		}
+	}
+	return ids
+}
+
+// OrderWriter writes the orders in one of the output formats
+type OrderWriter interface {
+	Write(o *Order) error
+	Close() error
+}
+
+// formats of the order export with their output files
+var orderFormats = map[string][]string{
+	"legacy":     {"stock_orders.csv"},
+	"csv":        {"stock_orders.csv"},
+	"jsonl":      {"stock_orders.jsonl"},
+	"normalized": {"orders.csv", "order_items.csv"},
+}
+
+// OrderOutputs returns the files the format writes in the folder
+func OrderOutputs(format, folder string) ([]string, error) {
+	files, ok := orderFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown order format %s", format)
+	}
+	outputs := make([]string, 0)
+	for _, f := range files {
+		outputs = append(outputs, filepath.Join(folder, f))
+	}
+	return outputs, nil
+}
+
+// NewOrderWriter creates the files of the format in the folder
+func NewOrderWriter(format, folder string) (OrderWriter, error) {
+	outputs, err := OrderOutputs(format, folder)
+	if err != nil {
+		return nil, err
+	}
+	var files []*csvFile
+	if format != "jsonl" {
+		for _, o := range outputs {
+			f, err := createCSVFile(o)
+			if err != nil {
+				for _, f := range files {
+					f.Close()
+				}
+				return nil, err
+			}
+			files = append(files, f)
+		}
+	}
+	switch format {
+	case "legacy":
+		return &legacyOrderWriter{files[0]}, nil
+	case "csv":
+		w := &csvOrderWriter{files[0]}
+		return w, w.header()
+	case "normalized":
+		w := &normalizedOrderWriter{orders: files[0], items: files[1]}
+		return w, w.header()
+	}
+	f, err := os.Create(outputs[0])
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file %s", err)
+	}
+	return &jsonOrderWriter{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// csvFile is a csv writer of a file, the writer is flushed on close
+type csvFile struct {
+	*csv.Writer
+	f *os.File
+}
+
+func createCSVFile(path string) (*csvFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file %s", err)
+	}
+	return &csvFile{Writer: csv.NewWriter(f), f: f}, nil
+}
+
+func (cf *csvFile) Close() error {
+	cf.Flush()
+	if err := cf.Error(); err != nil {
+		cf.f.Close()
+		return fmt.Errorf("unable to finish csv writing %s", err)
+	}
+	return cf.f.Close()
+}
+
+// legacyOrderWriter writes the date and email of an order followed by
+// its plasmids and strains without a header, the unknown items are left
+// out as they always were
+type legacyOrderWriter struct {
+	*csvFile
+}
+
+func (w *legacyOrderWriter) Write(o *Order) error {
+	row := []string{o.Date.Format(layout), o.Email}
+	row = append(row, o.ItemIDs(PlasmidItem)...)
+	row = append(row, o.ItemIDs(StrainItem)...)
+	return w.csvFile.Write(row)
+}
+
+// csvOrderWriter writes an order per row with the plasmids, strains and
+// unknown items in their own columns separated by semicolons
+type csvOrderWriter struct {
+	*csvFile
+}
+
+func (w *csvOrderWriter) header() error {
+	return w.csvFile.Write([]string{"order_id", "order_date", "email", "plasmids", "strains", "unknown"})
+}
+
+func (w *csvOrderWriter) Write(o *Order) error {
+	return w.csvFile.Write([]string{
+		strconv.FormatInt(o.ID, 10),
+		o.Date.Format(layout),
+		o.Email,
+		strings.Join(o.ItemIDs(PlasmidItem), ";"),
+		strings.Join(o.ItemIDs(StrainItem), ";"),
+		strings.Join(o.ItemIDs(UnknownItem), ";"),
+	})
+}
+
+// normalizedOrderWriter writes the orders and their items to separate
+// files that are joined by the order id
+type normalizedOrderWriter struct {
+	orders *csvFile
+	items  *csvFile
+}
+
+func (w *normalizedOrderWriter) header() error {
+	if err := w.orders.Write([]string{"order_id", "order_date", "email"}); err != nil {
+		return err
+	}
+	return w.items.Write([]string{"order_id", "kind", "item"})
+}
+
+func (w *normalizedOrderWriter) Write(o *Order) error {
+	id := strconv.FormatInt(o.ID, 10)
+	if err := w.orders.Write([]string{id, o.Date.Format(layout), o.Email}); err != nil {
+		return err
+	}
+	for _, it := range o.Items {
+		if err := w.items.Write([]string{id, it.Kind, it.ID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *normalizedOrderWriter) Close() error {
+	err := w.orders.Close()
+	if ierr := w.items.Close(); err == nil {
+		err = ierr
+	}
+	return err
+}
+
+// jsonOrderWriter writes an order per line as json
+type jsonOrderWriter struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+func (w *jsonOrderWriter) Write(o *Order) error {
+	return w.enc.Encode(o)
+}
+
+func (w *jsonOrderWriter) Close() error {
+	return w.f.Close()
+}
+
+// readOrderItems adds the items of a kind to their orders, the query
+// returns the order id and the item
+func readOrderItems(ctx context.Context, dbh *sql.DB, query, kind string, items map[int64][]OrderItem) error {
+	rows, err := dbh.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("unable to run %s query %s", kind, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			orderID int64
+			id      string
+		)
+		if err := rows.Scan(&orderID, &id); err != nil {
+			return fmt.Errorf("unable to scan the next row for %s %s", kind, err)
+		}
+		items[orderID] = append(items[orderID], OrderItem{Kind: kind, ID: id})
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("unable to close the rows for %s %s", kind, err)
+	}
+	return nil
+}
+
+// ReadOrders reads every order with its plasmids, strains and unknown
+// items and passes it to the function. The items of all the orders are
+// read first with a query per kind and joined with the orders in memory,
+// so only a single cursor is open at a time.
+func ReadOrders(ctx context.Context, dbh *sql.DB, fn func(*Order) error) error {
+	return ReadOrdersSince(ctx, dbh, time.Time{}, fn)
+}
+
+// ReadOrdersSince is ReadOrders for the orders placed since a time, every
+// order is read for a zero time
+func ReadOrdersSince(ctx context.Context, dbh *sql.DB, since time.Time, fn func(*Order) error) error {
+	items := make(map[int64][]OrderItem)
+	if err := readOrderItems(ctx, dbh, plasmidOrderItems, PlasmidItem, items); err != nil {
+		return err
+	}
+	if err := readOrderItems(ctx, dbh, strainOrderItems, StrainItem, items); err != nil {
+		return err
+	}
+	if err := readOrderItems(ctx, dbh, unknownOrderItems, UnknownItem, items); err != nil {
+		return err
+	}
+	where, args := sinceClause("sorder.order_date", since)
+	rows, err := dbh.QueryContext(ctx, fmt.Sprintf(listOrders, where), args...)
+	if err != nil {
+		return fmt.Errorf("unable to run query %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		o := new(Order)
+		if err := rows.Scan(&o.Email, &o.Date, &o.ID); err != nil {
+			return fmt.Errorf("unable to scan the next row %s", err)
+		}
+		// plasmids come before strains and unknown items as they are read first
+		o.Items = append(make([]OrderItem, 0), items[o.ID]...)
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("unable to close the rows for list of orders %s", err)
+	}
+	return nil
+}
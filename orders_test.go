@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOrders() []*Order {
+	return []*Order{
+		{
+			ID:    11,
+			Date:  time.Date(2011, 3, 4, 10, 20, 30, 0, time.UTC),
+			Email: "jane@example.org",
+			Items: []OrderItem{
+				{Kind: PlasmidItem, ID: "pDXA-GFP2"},
+				{Kind: StrainItem, ID: "DBS0235559"},
+				{Kind: StrainItem, ID: "DBS0236123"},
+			},
+		},
+		{
+			ID:    12,
+			Date:  time.Date(2012, 1, 2, 0, 0, 0, 0, time.UTC),
+			Email: "joe@example.org",
+			Items: []OrderItem{},
+		},
+	}
+}
+
+func TestOrderWriter(t *testing.T) {
+	orders := testOrders()
+	orders[0].Items = append(orders[0].Items, OrderItem{Kind: UnknownItem, ID: "Ax4 vector"})
+	cases := map[string]map[string]string{
+		"legacy": {
+			"stock_orders.csv": "2011-03-04 10:20:30,jane@example.org,pDXA-GFP2,DBS0235559,DBS0236123\n" +
+				"2012-01-02 00:00:00,joe@example.org\n",
+		},
+		"csv": {
+			"stock_orders.csv": "order_id,order_date,email,plasmids,strains,unknown\n" +
+				"11,2011-03-04 10:20:30,jane@example.org,pDXA-GFP2,DBS0235559;DBS0236123,Ax4 vector\n" +
+				"12,2012-01-02 00:00:00,joe@example.org,,,\n",
+		},
+		"jsonl": {
+			"stock_orders.jsonl": `{"order_id":11,"date":"2011-03-04T10:20:30Z","email":"jane@example.org","items":[{"kind":"plasmid","id":"pDXA-GFP2"},{"kind":"strain","id":"DBS0235559"},{"kind":"strain","id":"DBS0236123"},{"kind":"unknown","id":"Ax4 vector"}]}` + "\n" +
+				`{"order_id":12,"date":"2012-01-02T00:00:00Z","email":"joe@example.org","items":[]}` + "\n",
+		},
+		"normalized": {
+			"orders.csv": "order_id,order_date,email\n" +
+				"11,2011-03-04 10:20:30,jane@example.org\n" +
+				"12,2012-01-02 00:00:00,joe@example.org\n",
+			"order_items.csv": "order_id,kind,item\n" +
+				"11,plasmid,pDXA-GFP2\n11,strain,DBS0235559\n11,strain,DBS0236123\n11,unknown,Ax4 vector\n",
+		},
+	}
+	for format, files := range cases {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := NewOrderWriter(format, dir)
+			require.NoError(t, err)
+			for _, o := range orders {
+				require.NoError(t, w.Write(o))
+			}
+			require.NoError(t, w.Close())
+			outputs, err := OrderOutputs(format, dir)
+			require.NoError(t, err)
+			assert.Len(t, outputs, len(files))
+			for _, o := range outputs {
+				b, err := ioutil.ReadFile(o)
+				require.NoError(t, err)
+				assert.Equal(t, files[filepath.Base(o)], string(b))
+			}
+		})
+	}
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := NewOrderWriter("xml", t.TempDir())
+		assert.Error(t, err)
+	})
+}
+
+// orderFixture serves the orders and their items to ReadOrders
+func orderFixture(orders []*Order) *fixtureDB {
+	fdb := newFixtureDB()
+	var list [][]driver.Value
+	items := make(map[string][][]driver.Value)
+	for _, o := range orders {
+		list = append(list, []driver.Value{o.Email, o.Date, o.ID})
+		for _, it := range o.Items {
+			items[it.Kind] = append(items[it.Kind], []driver.Value{o.ID, it.ID})
+		}
+	}
+	fdb.table(fmt.Sprintf(listOrders, ""), []string{"email", "order_date", "stock_order_id"}, list)
+	fdb.table(plasmidOrderItems, []string{"order_id", "item"}, items[PlasmidItem])
+	fdb.table(strainOrderItems, []string{"order_id", "accession"}, items[StrainItem])
+	fdb.table(unknownOrderItems, []string{"order_id", "item"}, items[UnknownItem])
+	return fdb
+}
+
+func TestReadOrders(t *testing.T) {
+	fdb := orderFixture(testOrders())
+	var orders []*Order
+	err := ReadOrders(context.Background(), openFixtureDB(t, fdb), func(o *Order) error {
+		orders = append(orders, o)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, testOrders(), orders)
+	assert.Equal(t, 4, fdb.queries, "the items should be read with a query per kind")
+	assert.Equal(t, 1, fdb.maxOpen, "a single cursor should be open at a time")
+	assert.Equal(t, 0, fdb.open, "every cursor should be closed")
+	t.Run("per order", func(t *testing.T) {
+		fdb := orderFixture(testOrders())
+		perOrderFixture(fdb, testOrders())
+		var old []*Order
+		err := readOrdersPerOrder(context.Background(), openFixtureDB(t, fdb), func(o *Order) error {
+			old = append(old, o)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, orders, old, "the benchmarks should read the same orders")
+	})
+	t.Run("unknown items", func(t *testing.T) {
+		want := testOrders()
+		want[1].Items = append(want[1].Items, OrderItem{Kind: UnknownItem, ID: "Ax4 vector"})
+		var orders []*Order
+		err := ReadOrders(context.Background(), openFixtureDB(t, orderFixture(want)), func(o *Order) error {
+			orders = append(orders, o)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, want, orders)
+	})
+	t.Run("callback error", func(t *testing.T) {
+		err := ReadOrders(context.Background(), openFixtureDB(t, orderFixture(testOrders())), func(o *Order) error {
+			return errors.New("disk full")
+		})
+		assert.EqualError(t, err, "disk full")
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := ReadOrders(ctx, openFixtureDB(t, orderFixture(testOrders())), func(o *Order) error {
+			return nil
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), context.Canceled.Error(), "should stop with the job")
+	})
+}
+
+// benchmarkOrders are 10000 orders with up to 3 plasmids and 6 strains each
+func benchmarkOrders() []*Order {
+	var orders []*Order
+	for i := 0; i < 10000; i++ {
+		o := &Order{
+			ID:    int64(i),
+			Date:  time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Hour),
+			Email: fmt.Sprintf("user%d@example.org", i%500),
+		}
+		for j := 0; j < i%4; j++ {
+			o.Items = append(o.Items, OrderItem{Kind: PlasmidItem, ID: fmt.Sprintf("p%d-%d", i, j)})
+		}
+		for j := 0; j < i%7; j++ {
+			o.Items = append(o.Items, OrderItem{Kind: StrainItem, ID: fmt.Sprintf("DBS%07d", i*7+j)})
+		}
+		orders = append(orders, o)
+	}
+	return orders
+}
+
+func benchmarkReadOrders(b *testing.B, read func(context.Context, *sql.DB, func(*Order) error) error) {
+	orders := benchmarkOrders()
+	fdb := orderFixture(orders)
+	perOrderFixture(fdb, orders)
+	// a round trip to a remote database
+	fdb.latency = time.Millisecond
+	dbh := openFixtureDB(b, fdb)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		err := read(context.Background(), dbh, func(o *Order) error {
+			n++
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if n != len(orders) {
+			b.Fatalf("read %d orders instead of %d", n, len(orders))
+		}
+	}
+	b.ReportMetric(float64(fdb.queries)/float64(b.N), "queries/op")
+}
+
+func BenchmarkReadOrders(b *testing.B) {
+	benchmarkReadOrders(b, ReadOrders)
+}
+
+// the queries of the items of a single order that were run for every order
+// before they were read with a query per kind
+const (
+	perOrderPlasmids = `
+	SELECT DISTINCT sitem.item FROM CGM_DDB.stock_item_order sitem
+	JOIN CGM_DDB.plasmid ON sitem.item = plasmid.name
+	WHERE sitem.order_id = :1
+`
+	perOrderStrains = `
+SELECT DISTINCT dbxref.accession
+	FROM CGM_DDB.stock_center strain
+	JOIN CGM_DDB.stock_item_order sitem
+    ON strain.id= sitem.item_id
+    JOIN CGM_CHADO.dbxref
+    ON dbxref.dbxref_id = strain.dbxref_id
+	WHERE sitem.order_id = :1
+    AND sitem.stock_item_order_id NOT IN (
+        SELECT sitem.stock_item_order_id
+              FROM CGM_DDB.stock_item_order sitem
+              JOIN CGM_DDB.plasmid ON sitem.item = plasmid.name
+			  WHERE sitem.order_id = :2
+    )
+`
+)
+
+// readOrdersPerOrder reads the orders the way it was done before, with a
+// query per kind of item for every order
+func readOrdersPerOrder(ctx context.Context, dbh *sql.DB, fn func(*Order) error) error {
+	pOrderStmt, err := dbh.PrepareContext(ctx, perOrderPlasmids)
+	if err != nil {
+		return err
+	}
+	defer pOrderStmt.Close()
+	sOrderStmt, err := dbh.PrepareContext(ctx, perOrderStrains)
+	if err != nil {
+		return err
+	}
+	defer sOrderStmt.Close()
+	rows, err := dbh.QueryContext(ctx, fmt.Sprintf(listOrders, ""))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	items := func(stmt *sql.Stmt, o *Order, kind string, args ...interface{}) error {
+		irows, err := stmt.QueryContext(ctx, args...)
+		if err != nil {
+			return err
+		}
+		defer irows.Close()
+		for irows.Next() {
+			var id string
+			if err := irows.Scan(&id); err != nil {
+				return err
+			}
+			o.Items = append(o.Items, OrderItem{Kind: kind, ID: id})
+		}
+		return irows.Err()
+	}
+	for rows.Next() {
+		o := &Order{Items: make([]OrderItem, 0)}
+		if err := rows.Scan(&o.Email, &o.Date, &o.ID); err != nil {
+			return err
+		}
+		if err := items(pOrderStmt, o, PlasmidItem, o.ID); err != nil {
+			return err
+		}
+		if err := items(sOrderStmt, o, StrainItem, o.ID, o.ID); err != nil {
+			return err
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// perOrderFixture adds the per order item queries to the fixture of the
+// orders, the items are served by the order id of the first argument
+func perOrderFixture(fdb *fixtureDB, orders []*Order) {
+	byOrder := map[string]map[int64][][]driver.Value{
+		PlasmidItem: make(map[int64][][]driver.Value),
+		StrainItem:  make(map[int64][][]driver.Value),
+	}
+	for _, o := range orders {
+		for _, it := range o.Items {
+			byOrder[it.Kind][o.ID] = append(byOrder[it.Kind][o.ID], []driver.Value{it.ID})
+		}
+	}
+	for query, kind := range map[string]string{perOrderPlasmids: PlasmidItem, perOrderStrains: StrainItem} {
+		items := byOrder[kind]
+		fdb.tables[query] = func(args []driver.Value) (*fixtureTable, error) {
+			id, ok := args[0].(int64)
+			if !ok {
+				return nil, fmt.Errorf("order id %v is not an integer", args[0])
+			}
+			return &fixtureTable{columns: []string{"item"}, rows: items[id]}, nil
+		}
+	}
+}
+
+func BenchmarkReadOrdersPerOrder(b *testing.B) {
+	benchmarkReadOrders(b, readOrdersPerOrder)
+}
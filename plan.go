@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/migration-data-export/runner"
+	"github.com/urfave/cli"
+)
+
+// Plan is the list of jobs a command would run
+type Plan struct {
+	Command string     `json:"command"`
+	Jobs    []PlanStep `json:"jobs"`
+}
+
+// PlanStep describes a job of the plan, the jobs of a stage run
+// together after the earlier stages are completed
+type PlanStep struct {
+	Stage      int      `json:"stage"`
+	Name       string   `json:"name"`
+	Subcommand string   `json:"subcommand"`
+	Command    []string `json:"command"`
+	Configs    []string `json:"configs"`
+	Outputs    []string `json:"outputs"`
+}
+
+// NewPlan describes the stages of jobs of the command
+func NewPlan(c *cli.Context, stages ...[]runner.Job) *Plan {
+	p := &Plan{Command: c.Command.Name, Jobs: make([]PlanStep, 0)}
+	for i, stage := range stages {
+		for _, j := range stage {
+			p.Jobs = append(p.Jobs, PlanStep{
+				Stage:      i + 1,
+				Name:       j.Name,
+				Subcommand: j.Subcommand,
+				Command:    nonNil(j.Command),
+				Configs:    nonNil(j.Configs),
+				Outputs:    nonNil(j.Outputs),
+			})
+		}
+	}
+	return p
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return make([]string, 0)
+	}
+	return s
+}
+
+// Write prints the plan either as text or json
+func (p *Plan) Write(w io.Writer, format string) error {
+	if format == "json" {
+		b, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return fmt.Errorf("unable to encode plan %s", err)
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	}
+	fmt.Fprintf(w, "%s would run %d jobs\n", p.Command, len(p.Jobs))
+	for i, s := range p.Jobs {
+		fmt.Fprintf(w, "\n%d. %s(stage %d)\n", i+1, s.Name, s.Stage)
+		if len(s.Command) > 0 {
+			fmt.Fprintf(w, "   command: %s\n", strings.Join(s.Command, " "))
+		} else {
+			fmt.Fprintf(w, "   command: native export %s\n", s.Subcommand)
+		}
+		for _, conf := range s.Configs {
+			fmt.Fprintf(w, "   config:  %s\n", conf)
+		}
+		for _, out := range s.Outputs {
+			fmt.Fprintf(w, "   output:  %s\n", out)
+		}
+	}
+	return nil
+}
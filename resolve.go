@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// policies for the order items without a single stock id
+const (
+	SkipUnresolved = "skip"
+	KeepUnresolved = "keep"
+	FailUnresolved = "fail"
+)
+
+// reasons of an unresolved item
+const (
+	UnknownReason   = "unknown"
+	AmbiguousReason = "ambiguous"
+)
+
+// name of the report of the unresolved items of the orders
+const unresolvedReport = "stock_orders_unresolved.csv"
+
+var strainAccession = regexp.MustCompile(`^DBS\d{7}$`)
+
+// UnresolvedItem is an order item without a single stock id along with the
+// ids it could be
+type UnresolvedItem struct {
+	OrderID    int64
+	Kind       string
+	Item       string
+	Reason     string
+	Candidates []string
+}
+
+// ItemResolver turns the plasmid names of the orders into their DBP ids and
+// checks that the strains are DBS accessions, the items that could not be
+// resolved are kept, skipped or fail the export according to the policy
+type ItemResolver struct {
+	Policy     string
+	Unresolved []UnresolvedItem
+	ids        map[string]bool
+	names      map[string][]string
+	folded     map[string][]string
+}
+
+// validUnresolvedPolicy tells if the policy for unresolved items is known
+func validUnresolvedPolicy(policy string) bool {
+	switch policy {
+	case SkipUnresolved, KeepUnresolved, FailUnresolved:
+		return true
+	}
+	return false
+}
+
+// NewItemResolver resolves the names of the plasmids by the id and name
+// pairs of the plasmid table
+func NewItemResolver(policy string, plasmids [][2]string) (*ItemResolver, error) {
+	if !validUnresolvedPolicy(policy) {
+		return nil, fmt.Errorf("unknown policy %s for unresolved items", policy)
+	}
+	r := &ItemResolver{
+		Policy: policy,
+		ids:    make(map[string]bool),
+		names:  make(map[string][]string),
+		folded: make(map[string][]string),
+	}
+	for _, p := range plasmids {
+		id, name := p[0], p[1]
+		r.ids[id] = true
+		r.names[name] = append(r.names[name], id)
+		fold := foldName(name)
+		r.folded[fold] = append(r.folded[fold], id)
+	}
+	return r, nil
+}
+
+// foldName ignores the case and the surrounding spaces of a name
+func foldName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// ReadPlasmidIDs returns the id and name of every plasmid
+func ReadPlasmidIDs(ctx context.Context, dbh *sql.DB) ([][2]string, error) {
+	rows, err := dbh.QueryContext(ctx, plasmidNames)
+	if err != nil {
+		return nil, fmt.Errorf("unable to run query %s", err)
+	}
+	defer rows.Close()
+	var plasmids [][2]string
+	for rows.Next() {
+		var p [2]string
+		if err := rows.Scan(&p[0], &p[1]); err != nil {
+			return nil, fmt.Errorf("unable to scan the next row %s", err)
+		}
+		plasmids = append(plasmids, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to close the rows %s", err)
+	}
+	return plasmids, nil
+}
+
+// candidates are the ids of a plasmid, an exact match of the name wins
+// over a match that ignores the case and spaces
+func (r *ItemResolver) candidates(item string) []string {
+	if r.ids[item] {
+		return []string{item}
+	}
+	if ids, ok := r.names[item]; ok {
+		return ids
+	}
+	return r.folded[foldName(item)]
+}
+
+// Resolve replaces the items of the order with their ids, the unresolved
+// items are recorded and either kept as they are or left out. An error is
+// only returned for the fail policy.
+func (r *ItemResolver) Resolve(o *Order) error {
+	items := make([]OrderItem, 0, len(o.Items))
+	for _, it := range o.Items {
+		var ids []string
+		switch it.Kind {
+		case PlasmidItem, UnknownItem:
+			ids = r.candidates(it.ID)
+		case StrainItem:
+			if acc := strings.ToUpper(strings.TrimSpace(it.ID)); strainAccession.MatchString(acc) {
+				ids = []string{acc}
+			}
+		}
+		if len(ids) == 1 {
+			kind := it.Kind
+			if kind == UnknownItem {
+				// a plasmid name that only matches ignoring the case and spaces
+				kind = PlasmidItem
+			}
+			items = append(items, OrderItem{Kind: kind, ID: ids[0]})
+			continue
+		}
+		u := UnresolvedItem{OrderID: o.ID, Kind: it.Kind, Item: it.ID, Reason: UnknownReason}
+		if len(ids) > 1 {
+			u.Reason = AmbiguousReason
+			u.Candidates = append([]string{}, ids...)
+			sort.Strings(u.Candidates)
+		}
+		r.Unresolved = append(r.Unresolved, u)
+		if r.Policy == FailUnresolved {
+			return fmt.Errorf("%s %s %s of order %d", u.Reason, it.Kind, it.ID, o.ID)
+		}
+		if r.Policy == KeepUnresolved {
+			items = append(items, it)
+		}
+	}
+	o.Items = items
+	return nil
+}
+
+// WriteReport writes the unresolved items with a header to the folder
+func (r *ItemResolver) WriteReport(folder string) error {
+	f, err := createCSVFile(filepath.Join(folder, unresolvedReport))
+	if err != nil {
+		return err
+	}
+	if err := f.Write([]string{"order_id", "kind", "item", "reason", "candidates"}); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write csv header %s", err)
+	}
+	for _, u := range r.Unresolved {
+		err := f.Write([]string{
+			strconv.FormatInt(u.OrderID, 10),
+			u.Kind,
+			u.Item,
+			u.Reason,
+			strings.Join(u.Candidates, ";"),
+		})
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("unable to write csv row %s", err)
+		}
+	}
+	return f.Close()
+}
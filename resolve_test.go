@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resolverOrder() *Order {
+	return &Order{
+		ID: 11,
+		Items: []OrderItem{
+			{Kind: PlasmidItem, ID: "pDXA-GFP2"},
+			{Kind: PlasmidItem, ID: " pdxa-gfp2"},
+			{Kind: PlasmidItem, ID: "DBP0000027"},
+			{Kind: PlasmidItem, ID: "pLPBLP"},
+			{Kind: PlasmidItem, ID: "pGone"},
+			{Kind: StrainItem, ID: "dbs0235559 "},
+			{Kind: StrainItem, ID: "IF11"},
+			{Kind: UnknownItem, ID: "PDXA-GFP2"},
+			{Kind: UnknownItem, ID: "Ax4 vector"},
+		},
+	}
+}
+
+func TestItemResolver(t *testing.T) {
+	fdb := newFixtureDB()
+	fdb.table(plasmidNames, []string{"id", "name"}, [][]driver.Value{
+		{"DBP0000027", "pDXA-GFP2"},
+		{"DBP0000120", "pLPBLP"},
+		{"DBP0000121", "pLPBLP"},
+	})
+	plasmids, err := ReadPlasmidIDs(context.Background(), openFixtureDB(t, fdb))
+	require.NoError(t, err)
+	resolved := []OrderItem{
+		{Kind: PlasmidItem, ID: "DBP0000027"},
+		{Kind: PlasmidItem, ID: "DBP0000027"},
+		{Kind: PlasmidItem, ID: "DBP0000027"},
+	}
+	unresolved := []UnresolvedItem{
+		{OrderID: 11, Kind: PlasmidItem, Item: "pLPBLP", Reason: AmbiguousReason, Candidates: []string{"DBP0000120", "DBP0000121"}},
+		{OrderID: 11, Kind: PlasmidItem, Item: "pGone", Reason: UnknownReason},
+		{OrderID: 11, Kind: StrainItem, Item: "IF11", Reason: UnknownReason},
+		{OrderID: 11, Kind: UnknownItem, Item: "Ax4 vector", Reason: UnknownReason},
+	}
+	t.Run("skip", func(t *testing.T) {
+		r, err := NewItemResolver(SkipUnresolved, plasmids)
+		require.NoError(t, err)
+		o := resolverOrder()
+		require.NoError(t, r.Resolve(o))
+		assert.Equal(t, append(resolved,
+			OrderItem{Kind: StrainItem, ID: "DBS0235559"},
+			OrderItem{Kind: PlasmidItem, ID: "DBP0000027"},
+		), o.Items, "should take an unknown item that matches a plasmid name as the plasmid")
+		assert.Equal(t, unresolved, r.Unresolved)
+		dir := t.TempDir()
+		require.NoError(t, r.WriteReport(dir))
+		b, err := ioutil.ReadFile(filepath.Join(dir, unresolvedReport))
+		require.NoError(t, err)
+		assert.Equal(
+			t,
+			"order_id,kind,item,reason,candidates\n"+
+				"11,plasmid,pLPBLP,ambiguous,DBP0000120;DBP0000121\n"+
+				"11,plasmid,pGone,unknown,\n"+
+				"11,strain,IF11,unknown,\n"+
+				"11,unknown,Ax4 vector,unknown,\n",
+			string(b),
+		)
+	})
+	t.Run("keep", func(t *testing.T) {
+		r, err := NewItemResolver(KeepUnresolved, plasmids)
+		require.NoError(t, err)
+		o := resolverOrder()
+		require.NoError(t, r.Resolve(o))
+		assert.Equal(t, append(resolved,
+			OrderItem{Kind: PlasmidItem, ID: "pLPBLP"},
+			OrderItem{Kind: PlasmidItem, ID: "pGone"},
+			OrderItem{Kind: StrainItem, ID: "DBS0235559"},
+			OrderItem{Kind: StrainItem, ID: "IF11"},
+			OrderItem{Kind: PlasmidItem, ID: "DBP0000027"},
+			OrderItem{Kind: UnknownItem, ID: "Ax4 vector"},
+		), o.Items)
+		assert.Len(t, r.Unresolved, 4)
+	})
+	t.Run("fail", func(t *testing.T) {
+		r, err := NewItemResolver(FailUnresolved, plasmids)
+		require.NoError(t, err)
+		assert.EqualError(t, r.Resolve(resolverOrder()), "ambiguous plasmid pLPBLP of order 11")
+	})
+	t.Run("unknown policy", func(t *testing.T) {
+		_, err := NewItemResolver("guess", plasmids)
+		assert.Error(t, err)
+	})
+}
+
+func TestValidUnresolvedPolicy(t *testing.T) {
+	for _, p := range []string{SkipUnresolved, KeepUnresolved, FailUnresolved} {
+		assert.True(t, validUnresolvedPolicy(p), p)
+	}
+	assert.False(t, validUnresolvedPolicy("drop"))
+	_, err := NewItemResolver("drop", nil)
+	assert.Error(t, err)
+}
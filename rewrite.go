@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/migration-data-export/gff3"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v1"
+)
+
+// attributes holding IDs that are mapped to a new namespace by default
+var defaultIDAttributes = []string{"ID", "Parent", "Derives_from"}
+
+// RewriteRules are the transformations of gff3-rewrite, for example
+//
+//	dbxref:
+//	  drop: [GeneID]
+//	  rename:
+//	    DDB: dictyBase
+//	attributes:
+//	  drop: [Note]
+//	  rename:
+//	    Alias: Synonym
+//	id_prefixes:
+//	  DDB_G: dictyBase:DDB_G
+//	lookups:
+//	  - file: gene_names.tsv
+//	    key: ID
+type RewriteRules struct {
+	Dbxref       DbxrefRules       `yaml:"dbxref"`
+	Attributes   AttributeRules    `yaml:"attributes"`
+	IDPrefixes   map[string]string `yaml:"id_prefixes"`
+	IDAttributes []string          `yaml:"id_attributes"`
+	Lookups      []LookupRule      `yaml:"lookups"`
+}
+
+// DbxrefRules drop Dbxref entries by their database or by a prefix of the
+// whole entry and rename the databases
+type DbxrefRules struct {
+	Drop         []string          `yaml:"drop"`
+	DropPrefixes []string          `yaml:"drop_prefixes"`
+	Rename       map[string]string `yaml:"rename"`
+}
+
+// AttributeRules drop or rename attribute tags
+type AttributeRules struct {
+	Drop   []string          `yaml:"drop"`
+	Rename map[string]string `yaml:"rename"`
+}
+
+// LookupRule adds attributes from a tab separated file with a header, the
+// first column is matched with the value of the key attribute and the
+// other columns are added as attributes named by the header
+type LookupRule struct {
+	File string `yaml:"file"`
+	Key  string `yaml:"key"`
+}
+
+// LoadRewriteRules reads the rules file, the lookup files are
+// relative to the folder of the rules file
+func LoadRewriteRules(path string) (*RewriteRules, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read rules %s", err)
+	}
+	rules := new(RewriteRules)
+	if err := yaml.Unmarshal(b, rules); err != nil {
+		return nil, fmt.Errorf("unable to decode rules %s", err)
+	}
+	for i, l := range rules.Lookups {
+		if len(l.File) > 0 && !filepath.IsAbs(l.File) {
+			rules.Lookups[i].File = filepath.Join(filepath.Dir(path), l.File)
+		}
+	}
+	return rules, nil
+}
+
+type lookupTable struct {
+	key    string
+	values map[string]gff3.Attributes
+}
+
+// Rewriter applies the rules to features, the lookups are applied first
+// with the original values followed by the Dbxref, attribute and ID rules
+type Rewriter struct {
+	rules    *RewriteRules
+	lookups  []lookupTable
+	prefixes []string
+	idAttrs  []string
+}
+
+// NewRewriter validates the rules and loads the lookup tables
+func NewRewriter(rules *RewriteRules) (*Rewriter, error) {
+	rw := &Rewriter{rules: rules, idAttrs: rules.IDAttributes}
+	if len(rw.idAttrs) == 0 {
+		rw.idAttrs = defaultIDAttributes
+	}
+	for p := range rules.IDPrefixes {
+		rw.prefixes = append(rw.prefixes, p)
+	}
+	// the longest prefix wins
+	sort.Slice(rw.prefixes, func(i, j int) bool {
+		if len(rw.prefixes[i]) == len(rw.prefixes[j]) {
+			return rw.prefixes[i] < rw.prefixes[j]
+		}
+		return len(rw.prefixes[i]) > len(rw.prefixes[j])
+	})
+	for _, l := range rules.Lookups {
+		if len(l.File) == 0 || len(l.Key) == 0 {
+			return nil, fmt.Errorf("lookup needs both file and key")
+		}
+		lt, err := readLookup(l)
+		if err != nil {
+			return nil, err
+		}
+		rw.lookups = append(rw.lookups, lt)
+	}
+	return rw, nil
+}
+
+func readLookup(l LookupRule) (lookupTable, error) {
+	lt := lookupTable{key: l.Key, values: make(map[string]gff3.Attributes)}
+	f, err := os.Open(l.File)
+	if err != nil {
+		return lt, fmt.Errorf("unable to open lookup %s", err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comma = '\t'
+	r.LazyQuotes = true
+	header, err := r.Read()
+	if err != nil {
+		return lt, fmt.Errorf("unable to read header of lookup %s %s", l.File, err)
+	}
+	if len(header) < 2 {
+		return lt, fmt.Errorf("lookup %s needs at least two columns", l.File)
+	}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return lt, nil
+		}
+		if err != nil {
+			return lt, fmt.Errorf("unable to read lookup %s %s", l.File, err)
+		}
+		attrs := lt.values[row[0]]
+		for i, v := range row[1:] {
+			if len(v) > 0 {
+				attrs.Add(header[i+1], v)
+			}
+		}
+		lt.values[row[0]] = attrs
+	}
+}
+
+// Rewrite applies the rules to the feature and tells if it is changed
+func (rw *Rewriter) Rewrite(f *gff3.Feature) bool {
+	before := f.Attributes.String()
+	for _, lt := range rw.lookups {
+		for _, v := range f.Attributes.Get(lt.key) {
+			for _, at := range lt.values[v] {
+				f.Attributes.Add(at.Tag, at.Values...)
+			}
+		}
+	}
+	rw.rewriteDbxref(f)
+	rw.rewriteTags(f)
+	rw.rewriteIDs(f)
+	return before != f.Attributes.String()
+}
+
+func (rw *Rewriter) rewriteDbxref(f *gff3.Feature) {
+	rules := rw.rules.Dbxref
+	if !f.Attributes.Has("Dbxref") {
+		return
+	}
+	var xrefs []string
+	for _, xref := range f.Attributes.Get("Dbxref") {
+		db, acc := xref, ""
+		if i := strings.Index(xref, ":"); i >= 0 {
+			db, acc = xref[:i], xref[i:]
+		}
+		if contains(rules.Drop, db) || hasDbPrefix(xref, rules.DropPrefixes) {
+			continue
+		}
+		if to, ok := rules.Rename[db]; ok {
+			xref = to + acc
+		}
+		xrefs = append(xrefs, xref)
+	}
+	if len(xrefs) == 0 {
+		f.Attributes.Delete("Dbxref")
+		return
+	}
+	f.Attributes.Set("Dbxref", xrefs...)
+}
+
+func (rw *Rewriter) rewriteTags(f *gff3.Feature) {
+	rules := rw.rules.Attributes
+	if len(rules.Drop) == 0 && len(rules.Rename) == 0 {
+		return
+	}
+	attrs := make(gff3.Attributes, 0, len(f.Attributes))
+	for _, at := range f.Attributes {
+		if contains(rules.Drop, at.Tag) {
+			continue
+		}
+		tag := at.Tag
+		if to, ok := rules.Rename[tag]; ok {
+			tag = to
+		}
+		attrs.Add(tag, at.Values...)
+	}
+	f.Attributes = attrs
+}
+
+func (rw *Rewriter) rewriteIDs(f *gff3.Feature) {
+	if len(rw.prefixes) == 0 {
+		return
+	}
+	for _, tag := range rw.idAttrs {
+		values := f.Attributes.Get(tag)
+		if values == nil {
+			continue
+		}
+		mapped := make([]string, 0, len(values))
+		for _, v := range values {
+			mapped = append(mapped, rw.mapID(v))
+		}
+		f.Attributes.Set(tag, mapped...)
+	}
+}
+
+func (rw *Rewriter) mapID(id string) string {
+	for _, p := range rw.prefixes {
+		if strings.HasPrefix(id, p) {
+			return rw.rules.IDPrefixes[p] + strings.TrimPrefix(id, p)
+		}
+	}
+	return id
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RewriteGFF3 applies the rules to every feature of the stream and
+// returns the number of features and how many of them are changed
+func RewriteGFF3(in io.Reader, out io.Writer, rw *Rewriter) (int, int, error) {
+	var total, changed int
+	w := gff3.NewWriter(out)
+	err := transformGFF3(in, func(rec *gff3.Record) error {
+		if rec.Kind == gff3.FeatureKind {
+			total++
+			if rw.Rewrite(rec.Feature) {
+				changed++
+			}
+		}
+		return w.Write(rec)
+	})
+	if err != nil {
+		return total, changed, err
+	}
+	return total, changed, w.Flush()
+}
+
+func RewriteGFF3Action(c *cli.Context) error {
+	for _, p := range []string{"input", "output", "rules"} {
+		if !c.IsSet(p) {
+			return cli.NewExitError(fmt.Sprintf("argument %s is required", p), 2)
+		}
+	}
+	rules, err := LoadRewriteRules(c.String("rules"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	rw, err := NewRewriter(rules)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	in, err := os.Open(c.String("input"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("error in opening file %s", err), 2)
+	}
+	defer in.Close()
+	out, err := os.Create(c.String("output"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("error in writing file %s", err), 2)
+	}
+	defer out.Close()
+	total, changed, err := RewriteGFF3(in, out, rw)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	getLogger(c).Infof("rewrote %d of %d features of %s", changed, total, c.String("input"))
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/migration-data-export/gff3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteGFF3(t *testing.T) {
+	rules, err := LoadRewriteRules(filepath.Join("testdata", "gff3", "rewrite_rules.yml"))
+	require.NoError(t, err)
+	rw, err := NewRewriter(rules)
+	require.NoError(t, err)
+	in, err := os.Open(filepath.Join("testdata", "gff3", "canonical.gff3"))
+	require.NoError(t, err)
+	defer in.Close()
+	var out bytes.Buffer
+	total, changed, err := RewriteGFF3(in, &out, rw)
+	require.NoError(t, err)
+	assert := assert.New(t)
+	assert.Equal(6, total)
+	assert.Equal(3, changed)
+	assert.Equal(readFixture(t, "rewritten.gff3"), out.String())
+}
+
+func TestRewriter(t *testing.T) {
+	rw, err := NewRewriter(&RewriteRules{
+		Attributes: AttributeRules{Rename: map[string]string{"A": "B", "B": "C"}},
+		IDPrefixes: map[string]string{"DDB": "x:", "DDB_G": "gene:"},
+	})
+	require.NoError(t, err)
+	attrs, err := gff3.ParseAttributes("ID=DDB_G1;Parent=DDB2,other;A=1;B=2")
+	require.NoError(t, err)
+	f := &gff3.Feature{Attributes: attrs}
+	assert.True(t, rw.Rewrite(f))
+	assert.Equal(
+		t,
+		"ID=gene:1;Parent=x:2,other;B=1;C=2",
+		f.Attributes.String(),
+		"should rename every tag once and use the longest prefix",
+	)
+	_, err = NewRewriter(&RewriteRules{Lookups: []LookupRule{{File: "x.tsv"}}})
+	assert.Error(t, err)
+}
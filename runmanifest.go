@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/migration-data-export/runner"
+	"github.com/urfave/cli"
+)
+
+// runManifestFile is the name of the run manifest of a command in the
+// output folder, every command sharing the folder gets its own
+func runManifestFile(command string) string {
+	return fmt.Sprintf("manifest.%s.json", command)
+}
+
+// RunManifest records what a command produced in its output folder
+type RunManifest struct {
+	Command   string       `json:"command"`
+	Databases []string     `json:"databases"`
+	Start     time.Time    `json:"start"`
+	End       time.Time    `json:"end"`
+	Status    string       `json:"status"`
+	Jobs      []JobRecord  `json:"jobs"`
+	Files     []FileRecord `json:"files"`
+}
+
+// JobRecord is the outcome of a job of the run
+type JobRecord struct {
+	Name       string    `json:"name"`
+	Subcommand string    `json:"subcommand"`
+	Status     string    `json:"status"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Error      string    `json:"error,omitempty"`
+	Outputs    []string  `json:"outputs"`
+}
+
+// FileRecord describes an output file, the path is relative
+// to the output folder
+type FileRecord struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Sha256     string `json:"sha256"`
+	Lines      int64  `json:"lines"`
+	Records    int64  `json:"records"`
+	Job        string `json:"job,omitempty"`
+	Subcommand string `json:"subcommand,omitempty"`
+}
+
+// FileStat is the size, checksum and line count of a file
+type FileStat struct {
+	Size   int64
+	Sha256 string
+	Lines  int64
+}
+
+// StatFile calculates the size, sha256 checksum and number of lines of a file
+func StatFile(path string) (*FileStat, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	r := bufio.NewReader(io.TeeReader(f, h))
+	st := new(FileStat)
+	buf := make([]byte, 64*1024)
+	last := byte('\n')
+	for {
+		n, err := r.Read(buf)
+		for _, b := range buf[:n] {
+			if b == '\n' {
+				st.Lines++
+			}
+		}
+		if n > 0 {
+			last = buf[n-1]
+			st.Size += int64(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read file %s", err)
+		}
+	}
+	if last != '\n' {
+		st.Lines++
+	}
+	st.Sha256 = hex.EncodeToString(h.Sum(nil))
+	return st, nil
+}
+
+// CountRecords counts the data records of a file based on its format,
+// features for gff3, rows for csv and tsv, entries for bibtex and
+// lines for everything else
+func CountRecords(path string, lines int64) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("unable to open file %s", err)
+	}
+	defer f.Close()
+	switch filepath.Ext(path) {
+	case ".csv", ".tsv":
+		r := csv.NewReader(f)
+		if filepath.Ext(path) == ".tsv" {
+			r.Comma = '\t'
+		}
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		var count int64
+		for {
+			_, err := r.Read()
+			if err == io.EOF {
+				return count, nil
+			}
+			if err != nil {
+				return count, fmt.Errorf("unable to read %s %s", path, err)
+			}
+			count++
+		}
+	case ".gff3", ".bib":
+		gff3 := filepath.Ext(path) == ".gff3"
+		var count int64
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case gff3 && strings.HasPrefix(line, "##FASTA"):
+				return count, nil
+			case gff3 && len(line) > 0 && !strings.HasPrefix(line, "#"):
+				count++
+			case !gff3 && strings.HasPrefix(line, "@"):
+				count++
+			}
+		}
+		return count, scanner.Err()
+	}
+	return lines, nil
+}
+
+// connectionTargets describes the databases the command connects to
+// without the credentials
+func connectionTargets(c *cli.Context) []string {
+	targets := make([]string, 0)
+	for _, p := range [][2]string{{"user", "dsn"}, {"muser", "dsn"}, {"legacy-user", "legacy-dsn"}} {
+		if len(c.String(p[1])) > 0 && len(c.String(p[0])) > 0 {
+			targets = append(targets, fmt.Sprintf("%s@%s", c.String(p[0]), c.String(p[1])))
+		}
+	}
+	if len(c.String("host")) > 0 {
+		targets = append(targets, fmt.Sprintf(
+			"%s@%s:%s/%s",
+			c.String("user"), c.String("host"), c.String("port"), c.String("sid"),
+		))
+	}
+	return targets
+}
+
+// isBookkeeping tells if the file is written by the wrapper itself
+// to keep track of the runs
+func isBookkeeping(path string) bool {
+	for _, pattern := range []string{"manifest.*.json", "jobstate.*.json", "watermark.*.json"} {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// walkOutputs calls fn for every file of the outputs, the files of an
+// output folder are walked and missing outputs are left out
+func walkOutputs(outputs []string, fn func(path string) error) error {
+	seen := make(map[string]bool)
+	for _, o := range outputs {
+		if _, err := os.Stat(o); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		err := filepath.Walk(o, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || isBookkeeping(path) || seen[path] {
+				return nil
+			}
+			seen[path] = true
+			return fn(path)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jobForFile finds the job that produced the file, a job producing the file
+// itself wins over a job producing the folder. The file is not assigned if
+// more than one job writes to its folder.
+func jobForFile(path string, jobs []runner.Job) (runner.Job, bool) {
+	var folder []runner.Job
+	for _, j := range jobs {
+		for _, o := range j.Outputs {
+			switch {
+			case filepath.Clean(o) == path:
+				return j, true
+			case strings.HasPrefix(path, filepath.Clean(o)+string(filepath.Separator)):
+				folder = append(folder, j)
+			}
+		}
+	}
+	if len(folder) == 1 {
+		return folder[0], true
+	}
+	return runner.Job{}, false
+}
+
+// WriteRunManifest writes the manifest of the files produced by the jobs
+// of the command
+func WriteRunManifest(c *cli.Context, start time.Time, jobs []runner.Job, results runner.Results) error {
+	folder := c.String("output-folder")
+	rm := &RunManifest{
+		Command:   c.Command.Name,
+		Databases: connectionTargets(c),
+		Start:     start,
+		End:       time.Now(),
+		Status:    string(runner.Completed),
+		Jobs:      make([]JobRecord, 0),
+		Files:     make([]FileRecord, 0),
+	}
+	if results.Err() != nil {
+		rm.Status = string(runner.Failed)
+	}
+	var outputs []string
+	for i, r := range results {
+		jr := JobRecord{
+			Name:       r.Name,
+			Subcommand: jobs[i].Subcommand,
+			Status:     string(r.Status),
+			Start:      r.Start,
+			End:        r.End,
+			Outputs:    make([]string, 0),
+		}
+		if r.Err != nil {
+			jr.Error = redactor.Redact(r.Err.Error())
+		}
+		for _, o := range jobs[i].Outputs {
+			rel, err := filepath.Rel(folder, o)
+			if err != nil {
+				return fmt.Errorf("unable to locate output %s", err)
+			}
+			jr.Outputs = append(jr.Outputs, rel)
+		}
+		outputs = append(outputs, jobs[i].Outputs...)
+		rm.Jobs = append(rm.Jobs, jr)
+	}
+	err := walkOutputs(outputs, func(path string) error {
+		st, err := StatFile(path)
+		if err != nil {
+			return err
+		}
+		records, err := CountRecords(path, st.Lines)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(folder, path)
+		if err != nil {
+			return err
+		}
+		fr := FileRecord{
+			Path:    rel,
+			Size:    st.Size,
+			Sha256:  st.Sha256,
+			Lines:   st.Lines,
+			Records: records,
+		}
+		if j, ok := jobForFile(path, jobs); ok {
+			fr.Job = j.Name
+			fr.Subcommand = j.Subcommand
+		}
+		rm.Files = append(rm.Files, fr)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list output files %s", err)
+	}
+	sort.Slice(rm.Files, func(i, j int) bool { return rm.Files[i].Path < rm.Files[j].Path })
+	b, err := json.MarshalIndent(rm, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode manifest %s", err)
+	}
+	return ioutil.WriteFile(filepath.Join(folder, runManifestFile(rm.Command)), b, 0644)
+}
+
+// ReadRunManifest reads the run manifest of a command in an output folder
+func ReadRunManifest(folder, command string) (*RunManifest, error) {
+	b, err := ioutil.ReadFile(filepath.Join(folder, runManifestFile(command)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest %s", err)
+	}
+	rm := new(RunManifest)
+	if err := json.Unmarshal(b, rm); err != nil {
+		return nil, fmt.Errorf("unable to decode manifest %s", err)
+	}
+	return rm, nil
+}
+
+// manifestCommands returns the commands that have a run manifest in the folder
+func manifestCommands(folder string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(folder, runManifestFile("*")))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list manifests %s", err)
+	}
+	commands := make([]string, 0)
+	for _, p := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(p), "manifest."), ".json")
+		commands = append(commands, name)
+	}
+	return commands, nil
+}
+
+// VerifyRunManifest checks the files of a run manifest against the folder
+// and returns the problems that are found. Files within the job outputs
+// that are not listed in the manifest are reported as well.
+func VerifyRunManifest(folder string, rm *RunManifest) ([]string, error) {
+	var problems []string
+	listed := make(map[string]bool)
+	for _, fr := range rm.Files {
+		listed[fr.Path] = true
+		st, err := StatFile(filepath.Join(folder, fr.Path))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			problems = append(problems, fmt.Sprintf("missing %s", fr.Path))
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("unreadable %s %s", fr.Path, err))
+		case st.Size != fr.Size:
+			problems = append(problems, fmt.Sprintf("size mismatch %s expected %d got %d", fr.Path, fr.Size, st.Size))
+		case st.Sha256 != fr.Sha256:
+			problems = append(problems, fmt.Sprintf("checksum mismatch %s", fr.Path))
+		}
+	}
+	var outputs []string
+	for _, j := range rm.Jobs {
+		for _, o := range j.Outputs {
+			outputs = append(outputs, filepath.Join(folder, o))
+		}
+	}
+	err := walkOutputs(outputs, func(path string) error {
+		rel, err := filepath.Rel(folder, path)
+		if err != nil {
+			return err
+		}
+		if !listed[rel] {
+			problems = append(problems, fmt.Sprintf("unlisted %s", rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return problems, fmt.Errorf("unable to list files %s", err)
+	}
+	if rm.Status != string(runner.Completed) {
+		problems = append(problems, fmt.Sprintf("run of %s has status %s", rm.Command, rm.Status))
+	}
+	return problems, nil
+}
+
+func VerifyManifestAction(c *cli.Context) error {
+	if !c.IsSet("output-folder") {
+		return cli.NewExitError("argument output-folder is required", 2)
+	}
+	folder := c.String("output-folder")
+	commands := []string{c.String("command")}
+	if !c.IsSet("command") {
+		var err error
+		commands, err = manifestCommands(folder)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+		if len(commands) == 0 {
+			return cli.NewExitError(fmt.Sprintf("no manifest found in %s", folder), 2)
+		}
+	}
+	var count int
+	for _, command := range commands {
+		rm, err := ReadRunManifest(folder, command)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+		problems, err := VerifyRunManifest(folder, rm)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		count += len(problems)
+		if len(problems) == 0 {
+			fmt.Printf("verified %d files of %s in %s\n", len(rm.Files), rm.Command, folder)
+		}
+	}
+	if count > 0 {
+		return cli.NewExitError(
+			fmt.Sprintf("%d problems found in %s", count, folder),
+			2,
+		)
+	}
+	return nil
+}
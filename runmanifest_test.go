@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/migration-data-export/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+func runManifestContext(t *testing.T, command, folder string) *cli.Context {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("output-folder", folder, "")
+	c := cli.NewContext(nil, set, nil)
+	c.Command = cli.Command{Name: command}
+	return c
+}
+
+func TestWriteRunManifest(t *testing.T) {
+	folder := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(folder, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+	orders := write("stock_orders.csv", "order_id,item\n1,DBS0236123\n")
+	users := write("users/strain_user_annotations.csv", "id,user\n")
+	write("users/plasmid_user_annotations.csv", "id,user\n")
+	now := time.Now()
+	runs := map[string]runner.Job{
+		"dsc-orders": {Name: "stock_orders", Subcommand: "dscorders", Outputs: []string{orders}},
+		"dsc-users":  {Name: "strain_users", Subcommand: "dscusers", Outputs: []string{filepath.Dir(users)}},
+	}
+	for command, j := range runs {
+		results := runner.Results{{Name: j.Name, Status: runner.Completed, Start: now, End: now}}
+		require.NoError(t, WriteRunManifest(runManifestContext(t, command, folder), now, []runner.Job{j}, results))
+	}
+	t.Run("per command", func(t *testing.T) {
+		assert := assert.New(t)
+		rm, err := ReadRunManifest(folder, "dsc-orders")
+		require.NoError(t, err)
+		require.Len(t, rm.Files, 1, "should only list the output of its own jobs")
+		assert.Equal("stock_orders.csv", rm.Files[0].Path)
+		assert.Equal("stock_orders", rm.Files[0].Job)
+		assert.Equal(int64(2), rm.Files[0].Records)
+		rm, err = ReadRunManifest(folder, "dsc-users")
+		require.NoError(t, err)
+		require.Len(t, rm.Files, 2, "should list the files of an output folder")
+		assert.Equal("users/plasmid_user_annotations.csv", rm.Files[0].Path)
+		assert.Equal([]string{"users"}, rm.Jobs[0].Outputs)
+		commands, err := manifestCommands(folder)
+		require.NoError(t, err)
+		assert.ElementsMatch([]string{"dsc-orders", "dsc-users"}, commands)
+	})
+	t.Run("verify", func(t *testing.T) {
+		assert := assert.New(t)
+		rm, err := ReadRunManifest(folder, "dsc-orders")
+		require.NoError(t, err)
+		problems, err := VerifyRunManifest(folder, rm)
+		require.NoError(t, err)
+		assert.Empty(problems, "should not see the files of the other command")
+		write("users/extra.csv", "id\n")
+		write("stock_orders.csv", "order_id,item\n")
+		problems, err = VerifyRunManifest(folder, rm)
+		require.NoError(t, err)
+		assert.Equal([]string{"size mismatch stock_orders.csv expected 27 got 14"}, problems)
+		rm, err = ReadRunManifest(folder, "dsc-users")
+		require.NoError(t, err)
+		problems, err = VerifyRunManifest(folder, rm)
+		require.NoError(t, err)
+		assert.Equal([]string{"unlisted users/extra.csv"}, problems)
+	})
+}
@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// KillGrace is the time given to a process group to exit after being
+// terminated before it is killed
+var KillGrace = 10 * time.Second
+
+// Start starts the command in its own process group. The whole group is
+// terminated once the context is done, so that the children of the command
+// do not outlive it. The returned function waits for the command to exit.
+func Start(ctx context.Context, cmd *exec.Cmd) (func() error, error) {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		terminateProcessGroup(cmd)
+		select {
+		case <-done:
+		case <-time.After(KillGrace):
+			killProcessGroup(cmd)
+		}
+	}()
+	return func() error {
+		err := cmd.Wait()
+		close(done)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}, nil
+}
+
+// Exec runs the command in its own process group and waits for it to exit
+func Exec(ctx context.Context, cmd *exec.Cmd) error {
+	wait, err := Start(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	return wait()
+}
@@ -0,0 +1,170 @@
+// Package runner runs a list of export jobs with a bounded number of
+// them running at the same time and collects the result of every job.
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is the final state of a job
+type Status string
+
+const (
+	// Completed jobs ran without any error
+	Completed Status = "completed"
+	// Failed jobs returned an error
+	Failed Status = "failed"
+	// Killed jobs were stopped by cancellation or by their timeout
+	Killed Status = "killed"
+	// Cancelled jobs never started as the run was cancelled before
+	Cancelled Status = "cancelled"
+	// Skipped jobs were not run as asked by the runner
+	Skipped Status = "skipped"
+)
+
+// Job is a single unit of work, generally a run of a modware subprocess
+type Job struct {
+	Name string
+	Run  func(context.Context) error
+	// subcommand that the job runs, used for reporting
+	Subcommand string
+	// files or folders that the job writes, used for reporting
+	Outputs []string
+	// files that the job reads, used to tell if a job could be resumed
+	Inputs []string
+	// program and arguments of the subprocess, used for reporting
+	Command []string
+	// config files that the job reads, used for reporting
+	Configs []string
+}
+
+// Result is the outcome of a job
+type Result struct {
+	Name   string
+	Status Status
+	Err    error
+	Start  time.Time
+	End    time.Time
+}
+
+// Elapsed is the running time of the job
+func (r Result) Elapsed() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Results is the list of outcomes in the order of the jobs
+type Results []Result
+
+// Failed returns the results of the jobs that did not complete,
+// skipped jobs are not considered as failed
+func (rs Results) Failed() Results {
+	var failed Results
+	for _, r := range rs {
+		if r.Status != Completed && r.Status != Skipped {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// Err returns an error naming all the jobs that did not complete,
+// nil if all of them did
+func (rs Results) Err() error {
+	failed := rs.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	names := make([]string, 0)
+	for _, r := range failed {
+		names = append(names, fmt.Sprintf("%s(%s)", r.Name, r.Status))
+	}
+	return fmt.Errorf(
+		"%d of %d jobs did not complete: %s",
+		len(failed), len(rs), strings.Join(names, ", "),
+	)
+}
+
+// Runner runs jobs concurrently
+type Runner struct {
+	// maximum number of jobs running at the same time,
+	// all of them are run together when it is less than one
+	Parallel int
+	// maximum running time of every job, no limit when it is zero
+	Timeout time.Duration
+	// jobs for which it returns true are not run
+	Skip func(Job) bool
+	// called after every job is finished
+	Done func(Job, Result)
+}
+
+// New returns a Runner that runs at most parallel jobs at the same time
+func New(parallel int) *Runner {
+	return &Runner{Parallel: parallel}
+}
+
+// Run runs all the jobs and waits for them to finish. Once the context
+// is done the running jobs are cancelled and the pending ones are skipped.
+func (r *Runner) Run(ctx context.Context, jobs []Job) Results {
+	limit := r.Parallel
+	if limit < 1 || limit > len(jobs) {
+		limit = len(jobs)
+	}
+	results := make(Results, len(jobs))
+	sem := make(chan struct{}, limit)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, j Job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res := r.runJob(ctx, j)
+			results[i] = res
+			if r.Done != nil {
+				mu.Lock()
+				r.Done(j, res)
+				mu.Unlock()
+			}
+		}(i, j)
+	}
+	wg.Wait()
+	return results
+}
+
+func (r *Runner) runJob(ctx context.Context, j Job) Result {
+	res := Result{Name: j.Name, Start: time.Now()}
+	if err := ctx.Err(); err != nil {
+		res.End = res.Start
+		res.Status = Cancelled
+		res.Err = err
+		return res
+	}
+	if r.Skip != nil && r.Skip(j) {
+		res.End = res.Start
+		res.Status = Skipped
+		return res
+	}
+	jctx := ctx
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		jctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	res.Err = j.Run(jctx)
+	res.End = time.Now()
+	switch {
+	case res.Err == nil:
+		res.Status = Completed
+	case jctx.Err() != nil:
+		res.Status = Killed
+		res.Err = fmt.Errorf("%s %s", jctx.Err(), res.Err)
+	default:
+		res.Status = Failed
+	}
+	return res
+}
@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunner_Run(t *testing.T) {
+	t.Run("limits the number of running jobs", func(t *testing.T) {
+		var running, peak int32
+		jobs := make([]Job, 0)
+		for i := 0; i < 8; i++ {
+			jobs = append(jobs, Job{
+				Name: fmt.Sprintf("job%d", i),
+				Run: func(context.Context) error {
+					n := atomic.AddInt32(&running, 1)
+					for {
+						p := atomic.LoadInt32(&peak)
+						if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+					atomic.AddInt32(&running, -1)
+					return nil
+				},
+			})
+		}
+		rs := New(3).Run(context.Background(), jobs)
+		assert.Len(t, rs, 8)
+		assert.LessOrEqual(t, peak, int32(3))
+		assert.NoError(t, rs.Err())
+	})
+
+	t.Run("collects the failed jobs in order", func(t *testing.T) {
+		var done int32
+		r := New(0)
+		r.Done = func(Job, Result) { atomic.AddInt32(&done, 1) }
+		rs := r.Run(context.Background(), []Job{
+			{Name: "ok", Run: func(context.Context) error { return nil }},
+			{Name: "bad", Run: func(context.Context) error { return fmt.Errorf("boom") }},
+			{Name: "worse", Run: func(context.Context) error { return fmt.Errorf("bang") }},
+		})
+		assert.Equal(t, int32(3), done)
+		assert.Equal(t, Completed, rs[0].Status)
+		failed := rs.Failed()
+		assert.Len(t, failed, 2)
+		assert.Equal(t, Failed, failed[0].Status)
+		assert.EqualError(t, rs.Err(), "2 of 3 jobs did not complete: bad(failed), worse(failed)")
+	})
+
+	t.Run("kills the jobs running past the timeout", func(t *testing.T) {
+		r := New(1)
+		r.Timeout = 20 * time.Millisecond
+		rs := r.Run(context.Background(), []Job{
+			{Name: "slow", Run: func(ctx context.Context) error {
+				return Exec(ctx, exec.Command("sleep", "5"))
+			}},
+			{Name: "fast", Run: func(context.Context) error { return nil }},
+		})
+		assert.Equal(t, Killed, rs[0].Status)
+		assert.Less(t, rs[0].Elapsed(), 5*time.Second)
+		assert.Equal(t, Completed, rs[1].Status)
+	})
+
+	t.Run("skips the pending jobs once cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		rs := New(1).Run(ctx, []Job{
+			{Name: "first", Run: func(context.Context) error {
+				cancel()
+				return nil
+			}},
+			{Name: "second", Run: func(context.Context) error { return nil }},
+		})
+		assert.Equal(t, Completed, rs[0].Status)
+		assert.Equal(t, Cancelled, rs[1].Status)
+	})
+
+	t.Run("skips the jobs as asked", func(t *testing.T) {
+		r := New(0)
+		r.Skip = func(j Job) bool { return j.Name == "done" }
+		rs := r.Run(context.Background(), []Job{
+			{Name: "done", Run: func(context.Context) error { return fmt.Errorf("rerun") }},
+			{Name: "todo", Run: func(context.Context) error { return nil }},
+		})
+		assert.Equal(t, Skipped, rs[0].Status)
+		assert.Equal(t, Completed, rs[1].Status)
+		assert.NoError(t, rs.Err())
+	})
+
+	t.Run("runs nothing without jobs", func(t *testing.T) {
+		assert.Empty(t, New(2).Run(context.Background(), nil))
+	})
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// replacement of a secret in logs and error messages
+const redacted = "*****"
+
+// secretFlags maps the credential flags to the environment variables they
+// are read from, the value could also be given in a file that is named by
+// the same variable with a _FILE suffix
+var secretFlags = [][2]string{
+	{"password", "ORACLE_PASS"},
+	{"mpassword", "MULTI_ORACLE_PASS"},
+	{"legacy-password", "LEGACY_PASS"},
+}
+
+// userFlags are the flags of the user names, which are also the schema
+// names of the oracle databases
+var userFlags = []string{"user", "muser", "legacy-user"}
+
+// Redactor removes known secrets from text
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+	// secrets that are also a user or schema name, they are only
+	// replaced where a password is expected
+	positional []*regexp.Regexp
+}
+
+// secrets of the running command
+var redactor = &Redactor{}
+
+// Add registers a secret, empty values are ignored
+func (r *Redactor) Add(secret string) {
+	if len(secret) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.secrets {
+		if s == secret {
+			return
+		}
+	}
+	r.secrets = append(r.secrets, secret)
+	// longer secrets first so that a secret containing another is
+	// fully removed
+	sort.Slice(r.secrets, func(i, j int) bool {
+		return len(r.secrets[i]) > len(r.secrets[j])
+	})
+}
+
+// AddPositional registers a secret that is only replaced in credential
+// positions(password=secret or user/secret@dsn), for a password that is
+// the same as a user or schema name
+func (r *Redactor) AddPositional(secret string) {
+	if len(secret) == 0 {
+		return
+	}
+	q := regexp.QuoteMeta(secret)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.positional = append(
+		r.positional,
+		regexp.MustCompile(`((?i:password|passwd|pwd)\s*[=:]\s*["']?)`+q),
+		regexp.MustCompile(`(/)`+q+`(@)`),
+	)
+}
+
+// Redact replaces every secret in the text
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	for _, rgxp := range r.positional {
+		s = rgxp.ReplaceAllString(s, "${1}"+redacted+"${2}")
+	}
+	return s
+}
+
+// Levels implements logrus.Hook
+func (r *Redactor) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook, it redacts the message and the string
+// fields of the entry before it is written or sent to other hooks
+func (r *Redactor) Fire(entry *logrus.Entry) error {
+	entry.Message = r.Redact(entry.Message)
+	for k, v := range entry.Data {
+		switch val := v.(type) {
+		case string:
+			entry.Data[k] = r.Redact(val)
+		case error:
+			entry.Data[k] = r.Redact(val.Error())
+		}
+	}
+	return nil
+}
+
+// readSecretFile reads a secret from a file without the trailing newline
+func readSecretFile(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret %s", err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// ResolveSecrets fills in the credential flags of the command that are not
+// given. The value is read from the file named by the _FILE environment
+// variable or else from the docker secret named after the environment
+// variable(e.g. /run/secrets/oracle_pass). All the credentials are
+// registered with the redactor, a password that is also a user name is
+// only redacted in credential positions so that the schema names in the
+// logs stay readable.
+func ResolveSecrets(c *cli.Context) error {
+	for _, sf := range secretFlags {
+		name, env := sf[0], sf[1]
+		// not a flag of the command
+		if c.Generic(name) == nil {
+			continue
+		}
+		if len(c.String(name)) == 0 {
+			path := os.Getenv(env + "_FILE")
+			if len(path) == 0 {
+				path = filepath.Join(c.GlobalString("secrets-folder"), strings.ToLower(env))
+				if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+			}
+			secret, err := readSecretFile(path)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 2)
+			}
+			if err := c.Set(name, secret); err != nil {
+				return cli.NewExitError(fmt.Sprintf("unable to set %s %s", name, err), 2)
+			}
+		}
+		if isUserName(c, c.String(name)) {
+			redactor.AddPositional(c.String(name))
+			continue
+		}
+		redactor.Add(c.String(name))
+	}
+	return nil
+}
+
+// isUserName tells if the value is the same as any of the user names of
+// the command
+func isUserName(c *cli.Context, value string) bool {
+	for _, name := range userFlags {
+		if c.Generic(name) != nil && len(value) > 0 && strings.EqualFold(c.String(name), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// withCredentials resolves the connections and the credentials of the
+// command before running the given before function
+func withCredentials(before cli.BeforeFunc) cli.BeforeFunc {
+	return func(c *cli.Context) error {
+		if err := ResolveConnections(c); err != nil {
+			return err
+		}
+		if err := ResolveSecrets(c); err != nil {
+			return err
+		}
+		if before != nil {
+			return before(c)
+		}
+		return nil
+	}
+}
+
+// withRedactedErrors removes the secrets from the error of the action
+func withRedactedErrors(action func(*cli.Context) error) func(*cli.Context) error {
+	return func(c *cli.Context) error {
+		err := action(c)
+		if err == nil {
+			return nil
+		}
+		code := 1
+		var ec cli.ExitCoder
+		if errors.As(err, &ec) {
+			code = ec.ExitCode()
+		}
+		return cli.NewExitError(redactor.Redact(err.Error()), code)
+	}
+}
+
+// RemoveConfigs deletes the generated config files of the jobs as they
+// contain the credentials
+func RemoveConfigs(c *cli.Context, configs []string) {
+	if c.GlobalBool("keep-configs") {
+		return
+	}
+	for _, conf := range configs {
+		if err := os.Remove(conf); err != nil && !errors.Is(err, os.ErrNotExist) {
+			getLogger(c).Warnf("unable to remove config file %s %s", conf, err)
+		}
+	}
+}
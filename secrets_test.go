@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/migration-data-export/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+func TestRedactor(t *testing.T) {
+	r := &Redactor{}
+	r.Add("")
+	r.Add("secret")
+	r.Add("topsecret")
+	assert.Equal(
+		t,
+		"password ***** and *****",
+		r.Redact("password topsecret and secret"),
+		"should replace every secret",
+	)
+	assert.Equal(t, "nothing to hide", r.Redact("nothing to hide"))
+}
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "legacy_pass")
+	require.NoError(t, ioutil.WriteFile(path, []byte("fromfile\n"), 0600))
+	t.Setenv("LEGACY_PASS_FILE", path)
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("password", "given", "")
+	set.String("legacy-password", "", "")
+	c := cli.NewContext(nil, set, nil)
+	require.NoError(t, ResolveSecrets(c))
+	assert := assert.New(t)
+	assert.Equal("given", c.String("password"), "should keep the given password")
+	assert.Equal("fromfile", c.String("legacy-password"), "should read the password file")
+	assert.Equal("user ***** and *****", redactor.Redact("user given and fromfile"))
+}
+
+func TestDryRunRemovesConfigs(t *testing.T) {
+	global := flag.NewFlagSet("global", flag.ContinueOnError)
+	global.Bool("dry-run", true, "")
+	global.Bool("keep-configs", false, "")
+	global.String("plan-format", "json", "")
+	c := cli.NewContext(nil, flag.NewFlagSet("test", flag.ContinueOnError), cli.NewContext(nil, global, nil))
+	conf := filepath.Join(t.TempDir(), "colleague.yaml")
+	require.NoError(t, ioutil.WriteFile(conf, []byte("password: secret\n"), 0600))
+	job := runner.Job{Name: "colleague", Subcommand: "colleague", Configs: []string{conf}}
+	require.NoError(t, runJobs(c, []runner.Job{job}))
+	assert.NoFileExists(t, conf, "should remove the config with the credentials")
+}
+
+func TestRedactorPositional(t *testing.T) {
+	r := &Redactor{}
+	r.Add("topsecret")
+	r.AddPositional("CGM_DDB")
+	cases := map[string]string{
+		"ORA-00942: table CGM_DDB.STOCK_ORDER does not exist": "ORA-00942: table CGM_DDB.STOCK_ORDER does not exist",
+		"connecting as CGM_DDB/CGM_DDB@orcl":                  "connecting as CGM_DDB/*****@orcl",
+		"password=CGM_DDB user=CGM_DDB":                       "password=***** user=CGM_DDB",
+		`Password: "CGM_DDB"`:                                 `Password: "*****"`,
+		"login topsecret":                                     "login *****",
+	}
+	for in, expected := range cases {
+		in, expected := in, expected
+		t.Run(in, func(t *testing.T) {
+			assert.Equal(t, expected, r.Redact(in))
+		})
+	}
+}
+
+func TestResolveSecretsUserName(t *testing.T) {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("legacy-user", "CGM_DDB", "")
+	set.String("legacy-password", "CGM_DDB", "")
+	c := cli.NewContext(nil, set, nil)
+	require.NoError(t, ResolveSecrets(c))
+	assert.Equal(
+		t,
+		"schema CGM_DDB password=*****",
+		redactor.Redact("schema CGM_DDB password=CGM_DDB"),
+		"should keep the schema name readable",
+	)
+}
+
+func TestTeeRedacted(t *testing.T) {
+	redactor.Add("teesecret")
+	var tee, logged bytes.Buffer
+	w := &lineWriter{
+		log: func(args ...interface{}) { fmt.Fprintln(&logged, args...) },
+		tee: &tee,
+	}
+	_, err := w.Write([]byte("connecting with teesec"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("ret\nfailed\npartial teesecret"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "connecting with *****\nfailed\npartial *****", tee.String(), "should redact the tee output")
+	assert.Equal(t, "connecting with teesecret\nfailed\npartial teesecret\n", logged.String(), "should leave the redaction of the log to its hook")
+}
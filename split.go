@@ -0,0 +1,421 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/migration-data-export/gff3"
+	"github.com/urfave/cli"
+)
+
+// SplitRule routes the features that match any of its types, sequence
+// ontology terms or sources to an output. A sequence ontology term also
+// matches all of its descendants.
+type SplitRule struct {
+	Output  string
+	Types   []string
+	SOTerms []string
+	Sources []string
+}
+
+// ParseSplitRule parses a rule of the form
+// <output>=<type|so|source>:<value>[,<type|so|source>:<value>...]
+// for example ncrna.gff3=so:ncRNA,source:tRNAscan
+func ParseSplitRule(s string) (SplitRule, error) {
+	var r SplitRule
+	i := strings.LastIndex(s, "=")
+	if i <= 0 || i == len(s)-1 {
+		return r, fmt.Errorf("invalid split rule %q, expected output=matcher", s)
+	}
+	r.Output = s[:i]
+	for _, m := range strings.Split(s[i+1:], ",") {
+		kv := strings.SplitN(strings.TrimSpace(m), ":", 2)
+		if len(kv) != 2 || len(kv[1]) == 0 {
+			return r, fmt.Errorf("invalid matcher %q in split rule %q", m, s)
+		}
+		switch kv[0] {
+		case "type":
+			r.Types = append(r.Types, kv[1])
+		case "so":
+			r.SOTerms = append(r.SOTerms, kv[1])
+		case "source":
+			r.Sources = append(r.Sources, kv[1])
+		default:
+			return r, fmt.Errorf("unknown matcher %q in split rule %q", kv[0], s)
+		}
+	}
+	return r, nil
+}
+
+// Match tells if the feature is routed by the rule
+func (r SplitRule) Match(f *gff3.Feature, so *gff3.Ontology) bool {
+	for _, t := range r.Types {
+		if f.Type == t {
+			return true
+		}
+	}
+	for _, t := range r.SOTerms {
+		if so.IsA(f.Type, t) {
+			return true
+		}
+	}
+	for _, s := range r.Sources {
+		if f.Source == s {
+			return true
+		}
+	}
+	return false
+}
+
+// families joins the IDs of features that are linked through
+// their Parent attributes
+type families map[string]string
+
+func (fm families) find(id string) string {
+	root, ok := fm[id]
+	if !ok {
+		fm[id] = id
+		return id
+	}
+	if root == id {
+		return id
+	}
+	root = fm.find(root)
+	fm[id] = root
+	return root
+}
+
+func (fm families) union(a, b string) {
+	ra, rb := fm.find(a), fm.find(b)
+	if ra != rb {
+		fm[rb] = ra
+	}
+}
+
+// familyKey is the ID that links the feature to its family, features
+// without ID and parent stand alone
+func familyKey(f *gff3.Feature) string {
+	if id := f.ID(); len(id) > 0 {
+		return id
+	}
+	if p := f.Parents(); len(p) > 0 {
+		return p[0]
+	}
+	return ""
+}
+
+// Splitter writes the features of a gff3 file to the outputs of the
+// rules. A feature is kept with its parents and children, the whole
+// family goes to the output of the first rule that matches any of its
+// members and to the default output if none does.
+type Splitter struct {
+	Rules    []SplitRule
+	Ontology *gff3.Ontology
+}
+
+// splitPlan is what the first pass over the input learns
+type splitPlan struct {
+	version  string
+	header   []*gff3.Directive
+	regions  map[string]*gff3.Directive
+	order    []string
+	route    map[string]int
+	families families
+	seqids   []map[string]bool
+}
+
+func (s *Splitter) match(f *gff3.Feature) int {
+	for i, r := range s.Rules {
+		if r.Match(f, s.Ontology) {
+			return i
+		}
+	}
+	return len(s.Rules)
+}
+
+// plan decides the output of every family and the sequence regions
+// every output needs
+func (s *Splitter) plan(in io.Reader) (*splitPlan, error) {
+	p := &splitPlan{
+		version:  "3",
+		regions:  make(map[string]*gff3.Directive),
+		route:    make(map[string]int),
+		families: make(families),
+		seqids:   make([]map[string]bool, len(s.Rules)+1),
+	}
+	for i := range p.seqids {
+		p.seqids[i] = make(map[string]bool)
+	}
+	keyRule := make(map[string]int)
+	keySeqids := make(map[string]map[string]bool)
+	err := transformGFF3(in, func(rec *gff3.Record) error {
+		switch rec.Kind {
+		case gff3.DirectiveKind:
+			d := rec.Directive
+			switch d.Name {
+			case "gff-version":
+				p.version = d.Value
+			case "sequence-region":
+				seqid, _, _, err := d.SequenceRegion()
+				if err != nil {
+					return err
+				}
+				if _, ok := p.regions[seqid]; !ok {
+					p.order = append(p.order, seqid)
+				}
+				p.regions[seqid] = d
+			case "FASTA", "#":
+			default:
+				p.header = append(p.header, d)
+			}
+		case gff3.FeatureKind:
+			f := rec.Feature
+			rule := s.match(f)
+			key := familyKey(f)
+			if len(key) == 0 {
+				p.seqids[rule][f.SeqID] = true
+				return nil
+			}
+			p.families.find(key)
+			for _, parent := range f.Parents() {
+				p.families.union(parent, key)
+			}
+			if cur, ok := keyRule[key]; !ok || rule < cur {
+				keyRule[key] = rule
+			}
+			if keySeqids[key] == nil {
+				keySeqids[key] = make(map[string]bool)
+			}
+			keySeqids[key][f.SeqID] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for key, rule := range keyRule {
+		root := p.families.find(key)
+		if cur, ok := p.route[root]; !ok || rule < cur {
+			p.route[root] = rule
+		}
+	}
+	for key, seqids := range keySeqids {
+		rule := p.route[p.families.find(key)]
+		for id := range seqids {
+			p.seqids[rule][id] = true
+		}
+	}
+	return p, nil
+}
+
+// Split reads the input twice, the features of every rule are written to
+// the output of the same index and the rest to the default output. The
+// sequences go to the fasta output if it is given, otherwise they stay
+// in the default output. Rules could share an output.
+func (s *Splitter) Split(in io.ReadSeeker, outputs []io.Writer, def, fasta io.Writer) error {
+	if len(outputs) != len(s.Rules) {
+		return fmt.Errorf("expected %d outputs got %d", len(s.Rules), len(outputs))
+	}
+	if s.Ontology == nil {
+		s.Ontology = gff3.DefaultOntology()
+	}
+	p, err := s.plan(in)
+	if err != nil {
+		return err
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to rewind input %s", err)
+	}
+	// rules sharing an output write through the same writer
+	var writers []*gff3.Writer
+	slots := make(map[io.Writer]int)
+	ruleSlot := make([]int, len(s.Rules)+1)
+	all := append(append(make([]io.Writer, 0, len(outputs)+1), outputs...), def)
+	for i, o := range all {
+		slot, ok := slots[o]
+		if !ok {
+			slot = len(writers)
+			slots[o] = slot
+			writers = append(writers, gff3.NewWriter(o))
+		}
+		ruleSlot[i] = slot
+	}
+	seqids := make([]map[string]bool, len(writers))
+	for i := range seqids {
+		seqids[i] = make(map[string]bool)
+	}
+	for rule, ids := range p.seqids {
+		for id := range ids {
+			seqids[ruleSlot[rule]][id] = true
+		}
+	}
+	for i, w := range writers {
+		if err := w.WriteDirective(&gff3.Directive{Name: "gff-version", Value: p.version}); err != nil {
+			return err
+		}
+		for _, d := range p.header {
+			if err := w.WriteDirective(d); err != nil {
+				return err
+			}
+		}
+		for _, id := range p.order {
+			if !seqids[i][id] {
+				continue
+			}
+			if err := w.WriteDirective(p.regions[id]); err != nil {
+				return err
+			}
+		}
+	}
+	defw := writers[ruleSlot[len(s.Rules)]]
+	var fw *gff3.Writer
+	if fasta != nil {
+		fw = gff3.NewFastaWriter(fasta)
+	}
+	dirty := make([]bool, len(writers))
+	err = transformGFF3(in, func(rec *gff3.Record) error {
+		switch rec.Kind {
+		case gff3.FeatureKind:
+			f := rec.Feature
+			var rule int
+			if key := familyKey(f); len(key) > 0 {
+				rule = p.route[p.families.find(key)]
+			} else {
+				rule = s.match(f)
+			}
+			slot := ruleSlot[rule]
+			dirty[slot] = true
+			return writers[slot].WriteFeature(f)
+		case gff3.DirectiveKind:
+			if rec.Directive.Name != "#" {
+				return nil
+			}
+			// closes the open hierarchies only where features were written
+			for i, w := range writers {
+				if !dirty[i] {
+					continue
+				}
+				dirty[i] = false
+				if err := w.WriteDirective(rec.Directive); err != nil {
+					return err
+				}
+			}
+		case gff3.CommentKind:
+			return defw.WriteComment(rec.Comment)
+		case gff3.SequenceKind:
+			if fw != nil {
+				return fw.WriteSequence(rec.Sequence)
+			}
+			return defw.WriteSequence(rec.Sequence)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, w := range writers {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if fw != nil {
+		return fw.Flush()
+	}
+	return nil
+}
+
+func SplitGFF3Action(c *cli.Context) error {
+	for _, p := range []string{"input", "default"} {
+		if !c.IsSet(p) {
+			return cli.NewExitError(fmt.Sprintf("argument %s is required", p), 2)
+		}
+	}
+	if len(c.StringSlice("rule")) == 0 {
+		return cli.NewExitError("at least one rule is required", 2)
+	}
+	s := &Splitter{Ontology: gff3.DefaultOntology()}
+	if len(c.String("so-file")) > 0 {
+		obo, err := os.Open(c.String("so-file"))
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("error in opening file %s", err), 2)
+		}
+		defer obo.Close()
+		s.Ontology, err = gff3.ParseOBO(obo)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+	}
+	reserved := map[string]string{
+		filepath.Clean(c.String("input")):   "input",
+		filepath.Clean(c.String("default")): "default",
+	}
+	if len(c.String("fasta")) > 0 {
+		reserved[filepath.Clean(c.String("fasta"))] = "fasta"
+	}
+	for _, v := range c.StringSlice("rule") {
+		r, err := ParseSplitRule(v)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+		if name, ok := reserved[filepath.Clean(r.Output)]; ok {
+			return cli.NewExitError(
+				fmt.Sprintf("output %s of rule %q is also the %s file", r.Output, v, name),
+				2,
+			)
+		}
+		s.Rules = append(s.Rules, r)
+	}
+	in, err := os.Open(c.String("input"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("error in opening file %s", err), 2)
+	}
+	defer in.Close()
+	files := make(map[string]*os.File)
+	create := func(path string) (*os.File, error) {
+		path = filepath.Clean(path)
+		if f, ok := files[path]; ok {
+			return f, nil
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("error in writing file %s", err)
+		}
+		files[path] = f
+		return f, nil
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	outputs := make([]io.Writer, 0)
+	for _, r := range s.Rules {
+		f, err := create(r.Output)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+		outputs = append(outputs, f)
+	}
+	def, err := create(c.String("default"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	var fasta io.Writer
+	if len(c.String("fasta")) > 0 {
+		f, err := create(c.String("fasta"))
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+		fasta = f
+	}
+	if err := s.Split(in, outputs, def, fasta); err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("error in splitting file %s %s", c.String("input"), err),
+			2,
+		)
+	}
+	return nil
+}
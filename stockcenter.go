@@ -1,44 +1,57 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/csv"
 	"fmt"
-	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
 	"time"
 
-	_ "gopkg.in/rana/ora.v4"
+	"github.com/migration-data-export/runner"
 	"github.com/urfave/cli"
+	_ "gopkg.in/rana/ora.v4"
 )
 
 var outfolder string
 
 const layout = "2006-01-02 15:04:05"
 
-const plasmidOrder = `
-	SELECT DISTINCT sitem.item FROM CGM_DDB.stock_item_order sitem
+// plasmids of all the orders
+const plasmidOrderItems = `
+	SELECT DISTINCT sitem.order_id, sitem.item FROM CGM_DDB.stock_item_order sitem
 	JOIN CGM_DDB.plasmid ON sitem.item = plasmid.name
-	WHERE sitem.order_id = :1
+	ORDER BY sitem.order_id, sitem.item
 `
 
-const strainOrder = `
-SELECT DISTINCT dbxref.accession
+// items of all the orders that are neither a plasmid nor a strain
+const unknownOrderItems = `
+	SELECT DISTINCT sitem.order_id, sitem.item FROM CGM_DDB.stock_item_order sitem
+	WHERE NOT EXISTS (SELECT 1 FROM CGM_DDB.plasmid WHERE plasmid.name = sitem.item)
+	AND NOT EXISTS (SELECT 1 FROM CGM_DDB.stock_center strain WHERE strain.id = sitem.item_id)
+	ORDER BY sitem.order_id, sitem.item
+`
+
+// ids of the plasmids with their names for resolving the plasmids of the orders
+const plasmidNames = `
+	SELECT 'DBP' || LPAD(plasmid.id, 7, '0'), plasmid.name FROM CGM_DDB.PLASMID plasmid
+`
+
+// strains of all the orders, the items that are plasmids are left out
+const strainOrderItems = `
+SELECT DISTINCT sitem.order_id, dbxref.accession
 	FROM CGM_DDB.stock_center strain
 	JOIN CGM_DDB.stock_item_order sitem
     ON strain.id= sitem.item_id
     JOIN CGM_CHADO.dbxref
     ON dbxref.dbxref_id = strain.dbxref_id
-	WHERE sitem.order_id = :1
-    AND sitem.stock_item_order_id NOT IN (
+	WHERE sitem.stock_item_order_id NOT IN (
         SELECT sitem.stock_item_order_id
               FROM CGM_DDB.stock_item_order sitem
               JOIN CGM_DDB.plasmid ON sitem.item = plasmid.name
-			  WHERE sitem.order_id = :2
     )
+	ORDER BY sitem.order_id, dbxref.accession
 `
 
 const listOrders = `
@@ -51,9 +64,63 @@ const listOrders = `
 	  ON coll_email.colleague_no = colleague.colleague_no
 	  JOIN CGM_DDB.email
 	  ON email.email_no = coll_email.email_no
+	  %s
 	  ORDER BY sorder.order_date, email.email
 	`
 
+// keys of all the orders for the tombstones
+const orderKeys = `
+	SELECT TO_CHAR(sorder.stock_order_id) FROM CGM_DDB.stock_order sorder
+`
+
+const plasmidUsers = `
+	SELECT plasmid.id,plasmid.created_by,plasmid.date_created, plasmid.date_modified
+	FROM CGM_DDB.PLASMID plasmid
+	%s
+	`
+
+const plasmidKeys = `
+	SELECT 'DBP' || LPAD(plasmid.id, 7, '0') FROM CGM_DDB.PLASMID plasmid
+	`
+
+const strainUsers = `
+	SELECT dbxref.accession,sc.created_by, sc.date_created, sc.date_modified
+	FROM CGM_DDB.STOCK_CENTER sc
+	JOIN CGM_CHADO.DBXREF dbxref
+	ON sc.dbxref_id = dbxref.dbxref_id
+	%s
+	`
+
+const strainKeys = `
+	SELECT dbxref.accession
+	FROM CGM_DDB.STOCK_CENTER sc
+	JOIN CGM_CHADO.DBXREF dbxref
+	ON sc.dbxref_id = dbxref.dbxref_id
+	`
+
+// inventory of the strains by their accession
+const strainInventory = `
+	SELECT dbxref.accession, inv.location, inv.no_of_vials, inv.color, inv.stored_as,
+	  inv.storage_date, inv.storage_comments, inv.other_comments_and_feedback
+	FROM CGM_DDB.STOCK_CENTER sc
+	JOIN CGM_CHADO.DBXREF dbxref
+	ON sc.dbxref_id = dbxref.dbxref_id
+	JOIN CGM_DDB.STOCK_CENTER_INVENTORY inv
+	ON inv.strain_id = sc.id
+	ORDER BY dbxref.accession, inv.storage_date
+	`
+
+// inventory of the plasmids by the ids of exportPlasmidUsers, plasmids
+// are not kept in vials
+const plasmidInventory = `
+	SELECT 'DBP' || LPAD(plasmid.id, 7, '0'), inv.location, inv.color, inv.stored_as,
+	  inv.storage_date, inv.storage_comments, inv.other_comments_and_feedback
+	FROM CGM_DDB.PLASMID plasmid
+	JOIN CGM_DDB.PLASMID_INVENTORY inv
+	ON inv.plasmid_id = plasmid.id
+	ORDER BY plasmid.id, inv.storage_date
+	`
+
 func CreateFolder(folder string) error {
 	if _, err := os.Stat(folder); os.IsNotExist(err) {
 		if err := os.MkdirAll(folder, 0744); err != nil {
@@ -85,37 +152,64 @@ func validateDsc(c *cli.Context) error {
 	return nil
 }
 
-func validateDscOrder(c *cli.Context) error {
-	if !ValidateExtraArgs(c) {
-		return cli.NewExitError("one or more of required arguments are not provided", 2)
-	}
-	return nil
-}
-
 func getOracleConnection(c *cli.Context) (*sql.DB, error) {
-	dataSource := fmt.Sprintf(
-		"%s/%s@%s:%s/%s",
-		c.String("user"),
-		c.String("password"),
-		c.String("host"),
-		c.String("port"),
-		c.String("sid"),
-	)
-	return sql.Open("ora", dataSource)
+	p, err := connectionProfile(c)
+	if err != nil {
+		return nil, err
+	}
+	return sql.Open("ora", p.EZConnect())
 }
 
 func DscUsersAction(c *cli.Context) error {
-	CreateRequiredFolder(outfolder)
-	if err := exportPlasmidUsers(c); err != nil {
+	if _, err := ParseSince(c.String("since"), nil); err != nil {
 		return cli.NewExitError(err.Error(), 2)
 	}
-	if err := exportStrainUsers(c); err != nil {
-		return cli.NewExitError(err.Error(), 2)
+	// the output folder and the watermarks are only set up for a real run
+	var store *WatermarkStore
+	jobs := []runner.Job{
+		{
+			Name:       "plasmid_user_annotations",
+			Subcommand: c.Command.Name,
+			Outputs: deltaOutputs(
+				c, outfolder, "plasmid_user_annotations",
+				filepath.Join(outfolder, "plasmid_user_annotations.csv"),
+			),
+			Run: func(ctx context.Context) error {
+				d, err := newDeltaExport(c, store, "plasmid_user_annotations", plasmidKeys)
+				if err != nil {
+					return err
+				}
+				return exportPlasmidUsers(ctx, c, d)
+			},
+		},
+		{
+			Name:       "strain_user_annotations",
+			Subcommand: c.Command.Name,
+			Outputs: deltaOutputs(
+				c, outfolder, "strain_user_annotations",
+				filepath.Join(outfolder, "strain_user_annotations.csv"),
+			),
+			Run: func(ctx context.Context) error {
+				d, err := newDeltaExport(c, store, "strain_user_annotations", strainKeys)
+				if err != nil {
+					return err
+				}
+				return exportStrainUsers(ctx, c, d)
+			},
+		},
 	}
-	return nil
+	if !c.GlobalBool("dry-run") {
+		CreateRequiredFolder(outfolder)
+		var err error
+		store, err = OpenWatermarkStore(stateFolder(c), c.Command.Name)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 2)
+		}
+	}
+	return runJobs(c, jobs)
 }
 
-func exportPlasmidUsers(c *cli.Context) error {
+func exportPlasmidUsers(ctx context.Context, c *cli.Context, d *deltaExport) error {
 	log := getLogger(c)
 	outfile := filepath.Join(outfolder, "plasmid_user_annotations.csv")
 	writer, err := os.Create(outfile)
@@ -137,11 +231,8 @@ func exportPlasmidUsers(c *cli.Context) error {
 		createdOn  time.Time
 		modifiedOn time.Time
 	)
-	stmt := `
-	SELECT plasmid.id,plasmid.created_by,plasmid.date_created, plasmid.date_modified
-	FROM CGM_DDB.PLASMID plasmid
-	`
-	rows, err := dbh.Query(stmt)
+	where, args := sinceClause("plasmid.date_modified", d.since)
+	rows, err := dbh.QueryContext(ctx, fmt.Sprintf(plasmidUsers, where), args...)
 	if err != nil {
 		return cli.NewExitError(fmt.Sprintf("unable to run query %s", err), 2)
 	}
@@ -171,11 +262,14 @@ func exportPlasmidUsers(c *cli.Context) error {
 	if err := csv.Error(); err != nil {
 		return fmt.Errorf("unable to finish csv writing %s", err)
 	}
+	if err := d.finish(ctx, c, dbh, outfolder); err != nil {
+		return fmt.Errorf("unable to finish %s export %s", d.name, err)
+	}
 	log.Infof("finished writing annotations to %s", outfile)
 	return nil
 }
 
-func exportStrainUsers(c *cli.Context) error {
+func exportStrainUsers(ctx context.Context, c *cli.Context, d *deltaExport) error {
 	log := getLogger(c)
 	outfile := filepath.Join(outfolder, "strain_user_annotations.csv")
 	writer, err := os.Create(outfile)
@@ -197,13 +291,8 @@ func exportStrainUsers(c *cli.Context) error {
 		createdOn  time.Time
 		modifiedOn time.Time
 	)
-	stmt := `
-	SELECT dbxref.accession,sc.created_by, sc.date_created, sc.date_modified
-	FROM CGM_DDB.STOCK_CENTER sc
-	JOIN CGM_CHADO.DBXREF dbxref
-	ON sc.dbxref_id = dbxref.dbxref_id
-	`
-	rows, err := dbh.Query(stmt)
+	where, args := sinceClause("sc.date_modified", d.since)
+	rows, err := dbh.QueryContext(ctx, fmt.Sprintf(strainUsers, where), args...)
 	if err != nil {
 		return cli.NewExitError(fmt.Sprintf("unable to run query %s", err), 2)
 	}
@@ -233,6 +322,9 @@ func exportStrainUsers(c *cli.Context) error {
 	if err := csv.Error(); err != nil {
 		return cli.NewExitError(fmt.Sprintf("unable to finish csv writing %s", err), 2)
 	}
+	if err := d.finish(ctx, c, dbh, outfolder); err != nil {
+		return cli.NewExitError(fmt.Sprintf("unable to finish %s export %s", d.name, err), 2)
+	}
 	log.Infof("finished writing annotations to %s", outfile)
 	return nil
 }
@@ -240,201 +332,182 @@ func exportStrainUsers(c *cli.Context) error {
 func StockCenterAction(c *cli.Context) error {
 	CreateRequiredFolder(c.String("config-folder"))
 	CreateRequiredFolder(c.String("output-folder"))
-	errChan := make(chan error)
-	out := make(chan []byte)
+	var jobs []runner.Job
 	for _, scmd := range []string{"dictystrain", "dictyplasmid"} {
 		yc := MakeSCConfig(c, scmd)
 		CreateSCFolder(yc)
-		conf := make(map[string]string)
-		conf["config"] = yc
-		conf["dir"] = c.String("output-folder")
-		go RunDumpCmd(conf, scmd, errChan, out)
-	}
-
-	count := 1
-	//curr := time.Now()
-	for {
-		select {
-		case r := <-out:
-			log.Printf("\nfinished the %s\n succesfully", string(r))
-			count++
-			if count > 2 {
-				break
-			}
-		case err := <-errChan:
-			log.Printf("\nError %s in running command\n", err)
-			count++
-			if count > 2 {
-				break
-			}
-		default:
-			time.Sleep(100 * time.Millisecond)
-			//elapsed := time.Since(curr)
-			//fmt.Printf("\r%d:%d:%d\t", int(elapsed.Hours()), int(elapsed.Minutes()), int(elapsed.Seconds()))
+		conf := Options{
+			{"config", yc},
+			{"dir", c.String("output-folder")},
 		}
+		jobs = append(jobs, dumpJob(c, scmd, conf, scmd))
 	}
-	return nil
+	return runJobs(c, jobs)
 }
 
 func DscOrderAction(c *cli.Context) error {
-	log := getLogger(c)
-	outfile := filepath.Join(outfolder, "stock_orders.csv")
-	writer, err := os.Create(outfile)
+	outputs, err := OrderOutputs(c.String("format"), outfolder)
 	if err != nil {
-		return cli.NewExitError(fmt.Sprintf("unable to open file %s", err), 2)
+		return cli.NewExitError(err.Error(), 2)
 	}
-	defer writer.Close()
-	csv := csv.NewWriter(writer)
-
-	// database connection
-	dbh, err := getOracleConnection(c)
-	if err != nil {
-		return cli.NewExitError(fmt.Sprintf("error in connecting to database %s", err), 2)
+	if _, err := ParseSince(c.String("since"), nil); err != nil {
+		return cli.NewExitError(err.Error(), 2)
 	}
-	defer dbh.Close()
-	// prepared statements for repeated executions
-	pOrderStmt, err := dbh.Prepare(plasmidOrder)
-	if err != nil {
-		log.Errorf("error in preparing plasmid order statement %s", err)
+	if !validUnresolvedPolicy(c.String("unresolved")) {
 		return cli.NewExitError(
-			fmt.Sprintf("error in preparing plasmid order statement %s", err),
+			fmt.Sprintf("unresolved should be one of skip, keep or fail, got %s", c.String("unresolved")),
 			2,
 		)
 	}
-	sOrderStmt, err := dbh.Prepare(strainOrder)
-	if err != nil {
-		log.Errorf("error in preparing strain  order statement %s", err)
-		return cli.NewExitError(
-			fmt.Sprintf("error in preparing strain  order statement %s", err),
-			2,
-		)
+	if c.String("format") != "legacy" {
+		outputs = append(outputs, filepath.Join(outfolder, unresolvedReport))
 	}
-
-	log.Infof("start writing orders to file %s", outfile)
-	var (
-		stockOrderId int64
-		orderDate    time.Time
-		email        string
-	)
-	// list of orders
-	rows, err := dbh.Query(listOrders)
-	if err != nil {
-		log.Errorf("unable to run query %s", err)
-		return cli.NewExitError(fmt.Sprintf("unable to run query %s", err), 2)
+	// the output folder and the watermarks are only set up for a real run
+	var store *WatermarkStore
+	jobs := []runner.Job{
+		{
+			Name:       "stock_orders",
+			Subcommand: c.Command.Name,
+			Outputs:    deltaOutputs(c, outfolder, "stock_orders", outputs...),
+			Run: func(ctx context.Context) error {
+				d, err := newDeltaExport(c, store, "stock_orders", orderKeys)
+				if err != nil {
+					return err
+				}
+				return exportStockOrders(ctx, c, d)
+			},
+		},
 	}
-	defer rows.Close()
-	for rows.Next() {
-		err := rows.Scan(&email, &orderDate, &stockOrderId)
+	if !c.GlobalBool("dry-run") {
+		CreateRequiredFolder(outfolder)
+		store, err = OpenWatermarkStore(stateFolder(c), c.Command.Name)
 		if err != nil {
-			log.Errorf("error in scanning row %s", err)
-			return cli.NewExitError(fmt.Sprintf("unable to scan the next row %s", err), 2)
+			return cli.NewExitError(err.Error(), 2)
 		}
-		var order []string
-		order = append(order, orderDate.Format(layout), email)
+	}
+	return runJobs(c, jobs)
+}
 
-		// list of plasmids per order
-		prows, err := pOrderStmt.Query(stockOrderId)
-		if err != nil {
-			log.Errorf("unable to run plasmid query for order %d %s", stockOrderId, err)
-			return cli.NewExitError(fmt.Sprintf("unable to run query %s", err), 2)
-		}
-		defer prows.Close()
-		var plasmid string
-		for prows.Next() {
-			err := prows.Scan(&plasmid)
-			if err != nil {
-				log.Errorf("error in scanning row for plasmid %s", err)
-				return cli.NewExitError(fmt.Sprintf("unable to scan the next row for plasmid %s", err), 2)
-			}
-			order = append(order, plasmid)
-		}
-		err = prows.Err()
+func exportStockOrders(ctx context.Context, c *cli.Context, d *deltaExport) error {
+	log := getLogger(c)
+	w, err := NewOrderWriter(c.String("format"), outfolder)
+	if err != nil {
+		return err
+	}
+	dbh, err := getOracleConnection(c)
+	if err != nil {
+		w.Close()
+		return fmt.Errorf("error in connecting to database %s", err)
+	}
+	defer dbh.Close()
+	// the legacy format keeps the plasmid names as they were always written
+	var resolver *ItemResolver
+	if c.String("format") != "legacy" {
+		plasmids, err := ReadPlasmidIDs(ctx, dbh)
 		if err != nil {
-			log.Errorf("unable to close the rows for plasmid %s", err)
-			return cli.NewExitError(
-				fmt.Sprintf("unable to close the rows for plasmid %s", err),
-				2,
-			)
+			w.Close()
+			return err
 		}
-
-		// list of strains per order
-		srows, err := sOrderStmt.Query(stockOrderId, stockOrderId)
+		resolver, err = NewItemResolver(c.String("unresolved"), plasmids)
 		if err != nil {
-			log.Errorf("unable to run strain query for order %d %s", stockOrderId, err)
-			return cli.NewExitError(fmt.Sprintf("unable to run strain query %s", err), 2)
+			w.Close()
+			return err
 		}
-		defer srows.Close()
-		var strain string
-		for srows.Next() {
-			err := srows.Scan(&strain)
-			if err != nil {
-				log.Errorf("error in scanning row for strain %s", err)
-				return cli.NewExitError(fmt.Sprintf("unable to scan the next row for strain  %s", err), 2)
+	}
+	log.Infof("start writing orders in %s format to %s", c.String("format"), outfolder)
+	count := 0
+	err = ReadOrdersSince(ctx, dbh, d.since, func(o *Order) error {
+		count++
+		if resolver != nil {
+			if err := resolver.Resolve(o); err != nil {
+				return err
 			}
-			order = append(order, strain)
 		}
-		err = srows.Err()
-		if err != nil {
-			log.Errorf("unable to close the rows for plasmid strain %s", err)
-			return cli.NewExitError(
-				fmt.Sprintf("unable to close the rows for strain  %s", err),
-				2,
-			)
+		if err := w.Write(o); err != nil {
+			return fmt.Errorf("unable to write order %d %s", o.ID, err)
 		}
-		err = csv.Write(order)
-		if err != nil {
-			log.Errorf("unable to write csv row %s %s", strings.Join(order, ""), err)
-			return cli.NewExitError(
-				fmt.Sprintf("unable to write csv row %s %s", strings.Join(order, ""), err),
-				2,
-			)
+		return nil
+	})
+	if resolver != nil {
+		if rerr := resolver.WriteReport(outfolder); rerr != nil && err == nil {
+			err = rerr
 		}
-		err = rows.Err()
+	}
+	if err != nil {
+		w.Close()
+		log.Error(err)
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if resolver != nil && len(resolver.Unresolved) > 0 {
+		log.Warnf("%d order items without a stock id are listed in %s", len(resolver.Unresolved), unresolvedReport)
+	}
+	if err := d.finish(ctx, c, dbh, outfolder); err != nil {
+		return fmt.Errorf("unable to finish %s export %s", d.name, err)
+	}
+	log.Infof("finished writing %d orders to %s", count, outfolder)
+	return nil
+}
+
+func DscInventoryAction(c *cli.Context) error {
+	var jobs []runner.Job
+	for _, inv := range []struct {
+		name     string
+		query    string
+		hasVials bool
+	}{
+		{"strain_stocks", strainInventory, true},
+		{"plasmid_stocks", plasmidInventory, false},
+	} {
+		name, query, hasVials := inv.name, inv.query, inv.hasVials
+		output, err := InventoryOutput(c.String("format"), outfolder, name)
 		if err != nil {
-			log.Errorf("unable to close the rows for list of orders  %s", err)
-			return cli.NewExitError(
-				fmt.Sprintf("unable to close the rows for list of orders   %s", err),
-				2,
-			)
-		}
-		csv.Flush()
-		if err := csv.Error(); err != nil {
-			log.Errorf("unable to finish csv writing %s", err)
-			return cli.NewExitError(fmt.Sprintf("unable to finish csv writing %s", err), 2)
+			return cli.NewExitError(err.Error(), 2)
 		}
+		jobs = append(jobs, runner.Job{
+			Name:       name,
+			Subcommand: c.Command.Name,
+			Outputs:    []string{output},
+			Run: func(ctx context.Context) error {
+				return exportInventory(ctx, c, name, query, hasVials)
+			},
+		})
 	}
-	log.Infof("finished writing all orders  to %s", outfile)
-	return nil
+	if !c.GlobalBool("dry-run") {
+		CreateRequiredFolder(outfolder)
+	}
+	return runJobs(c, jobs)
 }
 
-func exportDscOrders(c *cli.Context) error {
+func exportInventory(ctx context.Context, c *cli.Context, name, query string, hasVials bool) error {
 	log := getLogger(c)
-	mainCmd, err := exec.LookPath("modware-export")
+	w, err := NewInventoryWriter(c.String("format"), outfolder, name)
 	if err != nil {
-		return fmt.Errorf("could not find binary %s", err)
+		return err
 	}
-	subCmd := makeOrderExportCmd(c)
-	log.Infof("running the command %s", strings.Join(subCmd, " "))
-	_, err = exec.Command(mainCmd, subCmd...).CombinedOutput()
+	dbh, err := getOracleConnection(c)
 	if err != nil {
-		return fmt.Errorf("error %s running the command %s", err, strings.Join(subCmd, " "))
+		w.Close()
+		return fmt.Errorf("error in connecting to database %s", err)
 	}
-	log.Info("successfully ran the command")
-	return nil
-}
-
-func makeOrderExportCmd(c *cli.Context) []string {
-	return []string{
-		"dscorders",
-		"--dsn",
-		c.String("legacy-dsn"),
-		"-u",
-		c.String("legacy-user"),
-		"-p",
-		c.String("legacy-password"),
-		"--so",
-		filepath.Join(c.String("output-folder"), "strain_orders.csv"),
-		"--po",
-		filepath.Join(c.String("output-folder"), "plasmid_orders.csv"),
+	defer dbh.Close()
+	count := 0
+	err = ReadInventory(ctx, dbh, query, hasVials, func(r *InventoryRecord) error {
+		count++
+		if err := w.Write(r); err != nil {
+			return fmt.Errorf("unable to write inventory of %s %s", r.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		w.Close()
+		return err
 	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	log.Infof("finished writing %d records of %s to %s", count, name, outfolder)
+	return nil
 }
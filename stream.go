@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// number of trailing stderr lines that are kept for error messages
+const tailSize = 5
+
+// lineWriter logs every line written to it and optionally copies
+// the output to another writer, the copied lines are redacted as well
+type lineWriter struct {
+	mu   sync.Mutex
+	log  func(args ...interface{})
+	tee  io.Writer
+	buf  []byte
+	tail []string
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.teeLine(string(w.buf[:i+1])); err != nil {
+			return 0, err
+		}
+		w.logLine(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush logs the last line that does not end with a newline
+func (w *lineWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.teeLine(string(w.buf))
+	w.logLine(string(w.buf))
+	w.buf = nil
+	return err
+}
+
+// teeLine copies a line to the tee writer without the secrets
+func (w *lineWriter) teeLine(line string) error {
+	if w.tee == nil {
+		return nil
+	}
+	_, err := io.WriteString(w.tee, redactor.Redact(line))
+	return err
+}
+
+// Tail returns the last few lines that were written
+func (w *lineWriter) Tail() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.Join(w.tail, "\n")
+}
+
+func (w *lineWriter) logLine(line string) {
+	line = strings.TrimRight(line, "\r")
+	if len(strings.TrimSpace(line)) == 0 {
+		return
+	}
+	w.log(line)
+	w.tail = append(w.tail, line)
+	if len(w.tail) > tailSize {
+		w.tail = w.tail[1:]
+	}
+}
+
+// JobLogger logs the activity and the subprocess output of a single job
+type JobLogger struct {
+	*logrus.Entry
+	folder string
+	job    string
+}
+
+// NewJobLogger returns a logger for the job, the output of the
+// subprocesses is also written to the log folder if the command
+// is asked to tee it
+func NewJobLogger(c *cli.Context, job string) *JobLogger {
+	jl := &JobLogger{
+		Entry: getLogger(c).WithField("job", job),
+		job:   job,
+	}
+	if c.Bool("tee-output") {
+		jl.folder = c.String("log-folder")
+	}
+	return jl
+}
+
+// CmdOutput is the streamed output of a subprocess
+type CmdOutput struct {
+	stdout *lineWriter
+	stderr *lineWriter
+	file   *os.File
+}
+
+// Close flushes the streams and closes the tee file
+func (o *CmdOutput) Close() error {
+	err := o.stdout.Flush()
+	if serr := o.stderr.Flush(); err == nil {
+		err = serr
+	}
+	if o.file != nil {
+		if cerr := o.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Tail returns the last few lines of stderr
+func (o *CmdOutput) Tail() string {
+	return o.stderr.Tail()
+}
+
+// Subcommand returns the logger of a subcommand of the job
+func (jl *JobLogger) Subcommand(subcmd string) *logrus.Entry {
+	return jl.WithField("subcommand", subcmd)
+}
+
+// Attach streams the stdout and stderr of the command to the logger line
+// by line. The stdout is left alone if it is already set, for example
+// when it is piped to another command.
+func (jl *JobLogger) Attach(cmd *exec.Cmd, subcmd string) (*CmdOutput, error) {
+	log := jl.Subcommand(subcmd)
+	out := &CmdOutput{
+		stdout: &lineWriter{log: log.WithField("stream", "stdout").Info},
+		stderr: &lineWriter{log: log.WithField("stream", "stderr").Warn},
+	}
+	if len(jl.folder) > 0 {
+		if err := CreateFolder(jl.folder); err != nil {
+			return nil, err
+		}
+		name := filepath.Join(jl.folder, fmt.Sprintf("%s_%s.output", jl.job, subcmd))
+		f, err := os.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create output log file %s", err)
+		}
+		out.file = f
+		out.stdout.tee = f
+		out.stderr.tee = f
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = out.stdout
+	}
+	cmd.Stderr = out.stderr
+	return out, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -98,3 +99,36 @@ func CreateFolderFromYaml(yf string) {
 	CreateRequiredFolder(filepath.Dir(yml.Output))
 	CreateRequiredFolder(filepath.Dir(yml.LogFile))
 }
+
+// CommandContext returns the context of the application which is
+// cancelled on interrupt
+func CommandContext(c *cli.Context) context.Context {
+	if ctx, ok := c.App.Metadata["context"].(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+// ConfigOutputs returns the output files and folders given in a
+// generated config file
+func ConfigOutputs(yf string) []string {
+	b, err := ioutil.ReadFile(yf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var yml struct {
+		Output    string `yaml:"output"`
+		XMLOutput string `yaml:"xml_output"`
+		Dir       string `yaml:"dir"`
+	}
+	if err := yaml.Unmarshal(b, &yml); err != nil {
+		log.Fatal(err)
+	}
+	var outputs []string
+	for _, o := range []string{yml.Output, yml.XMLOutput, yml.Dir} {
+		if len(o) > 0 {
+			outputs = append(outputs, o)
+		}
+	}
+	return outputs
+}
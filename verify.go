@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// checks of dsc-verify
+const (
+	UnknownStock      = "unknown_stock"
+	UnresolvedPlasmid = "unresolved_plasmid"
+	UnknownUser       = "unknown_user"
+	DuplicateID       = "duplicate_id"
+)
+
+// Violation is a row of an export that breaks one of the checks, the row
+// is counted from one without the header
+type Violation struct {
+	Check   string `json:"check"`
+	File    string `json:"file"`
+	Row     int    `json:"row"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+// VerifyReport lists the violations found in the stock center exports
+// along with the number of records read from every file, the items of
+// the orders and the distinct users are counted
+type VerifyReport struct {
+	Files      map[string]int `json:"files"`
+	Counts     map[string]int `json:"counts"`
+	Violations []Violation    `json:"violations"`
+}
+
+func (vr *VerifyReport) add(check, file string, row int, value, format string, args ...interface{}) {
+	vr.Counts[check]++
+	vr.Violations = append(vr.Violations, Violation{
+		Check:   check,
+		File:    file,
+		Row:     row,
+		Value:   value,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// VerifyInputs are the exports that are checked, the catalog files are
+// the strain and plasmid files written by dsc-annotations. The users are
+// only checked if the column of the users file is given.
+type VerifyInputs struct {
+	Folder         string
+	OrdersFormat   string
+	StrainCatalog  string
+	PlasmidCatalog string
+	UsersFile      string
+	UserColumn     string
+}
+
+// readCSVRecords calls the function with every record of a csv file and
+// its row number
+func readCSVRecords(path string, header bool, fn func(row int, rec []string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error in opening file %s", err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	row := 0
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error in reading %s %s", path, err)
+		}
+		if header {
+			header = false
+			continue
+		}
+		row++
+		if err := fn(row, rec); err != nil {
+			return err
+		}
+	}
+}
+
+// stockIDs are the ids of a catalog file with the rows they are found in
+type stockIDs map[string][]int
+
+// StockCatalog are the strains and plasmids of the catalog export, the
+// plasmids are also kept by name to resolve the names of the orders
+type StockCatalog struct {
+	Strains      stockIDs
+	Plasmids     stockIDs
+	PlasmidNames map[string][]string
+}
+
+// ReadStockCatalog reads the strain and plasmid files of the catalog, a
+// row per stock with its id first and the name of the plasmid second
+func ReadStockCatalog(strainFile, plasmidFile string, report *VerifyReport) (*StockCatalog, error) {
+	sc := &StockCatalog{
+		Strains:      make(stockIDs),
+		Plasmids:     make(stockIDs),
+		PlasmidNames: make(map[string][]string),
+	}
+	strainName := filepath.Base(strainFile)
+	err := readCSVRecords(strainFile, false, func(row int, rec []string) error {
+		report.Files[strainName]++
+		sc.Strains[rec[0]] = append(sc.Strains[rec[0]], row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	plasmidName := filepath.Base(plasmidFile)
+	err = readCSVRecords(plasmidFile, false, func(row int, rec []string) error {
+		if len(rec) < 2 {
+			return fmt.Errorf("row %d of %s has no plasmid name after the id", row, plasmidName)
+		}
+		report.Files[plasmidName]++
+		sc.Plasmids[rec[0]] = append(sc.Plasmids[rec[0]], row)
+		sc.PlasmidNames[rec[1]] = append(sc.PlasmidNames[rec[1]], rec[0])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// ResolvePlasmid returns the id of a plasmid given either by its id or its
+// name along with the ids of the name if there is not exactly one
+func (sc *StockCatalog) ResolvePlasmid(item string) (string, []string) {
+	if _, ok := sc.Plasmids[item]; ok {
+		return item, nil
+	}
+	ids := sc.PlasmidNames[item]
+	if len(ids) == 1 {
+		return ids[0], nil
+	}
+	return "", ids
+}
+
+// checkPlasmid reports a plasmid of an order that does not resolve to an id
+func (sc *StockCatalog) checkPlasmid(report *VerifyReport, file string, row int, item string) {
+	id, ids := sc.ResolvePlasmid(item)
+	switch {
+	case len(id) > 0:
+	case len(ids) > 1:
+		report.add(UnresolvedPlasmid, file, row, item, "plasmid name is shared by %s", strings.Join(ids, ","))
+	default:
+		report.add(UnresolvedPlasmid, file, row, item, "plasmid name has no id in the catalog")
+	}
+}
+
+// orderItems reads the items of the orders file of a format along with
+// their row, the items of the legacy format have no kind
+func orderItems(folder, format string, fn func(file string, row int, it OrderItem) error) error {
+	switch format {
+	case "legacy":
+		return readCSVRecords(filepath.Join(folder, "stock_orders.csv"), false, func(row int, rec []string) error {
+			for i := 2; i < len(rec); i++ {
+				if err := fn("stock_orders.csv", row, OrderItem{ID: rec[i]}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case "csv":
+		return readCSVRecords(filepath.Join(folder, "stock_orders.csv"), true, func(row int, rec []string) error {
+			if len(rec) < 6 {
+				return fmt.Errorf("row %d of stock_orders.csv has %d columns", row, len(rec))
+			}
+			for i, kind := range []string{PlasmidItem, StrainItem, UnknownItem} {
+				for _, id := range strings.Split(rec[3+i], ";") {
+					if len(id) == 0 {
+						continue
+					}
+					if err := fn("stock_orders.csv", row, OrderItem{Kind: kind, ID: id}); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	case "normalized":
+		return readCSVRecords(filepath.Join(folder, "order_items.csv"), true, func(row int, rec []string) error {
+			if len(rec) < 3 {
+				return fmt.Errorf("row %d of order_items.csv has %d columns", row, len(rec))
+			}
+			return fn("order_items.csv", row, OrderItem{Kind: rec[1], ID: rec[2]})
+		})
+	case "jsonl":
+		f, err := os.Open(filepath.Join(folder, "stock_orders.jsonl"))
+		if err != nil {
+			return fmt.Errorf("error in opening file %s", err)
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for row := 1; scanner.Scan(); row++ {
+			var o Order
+			if err := json.Unmarshal(scanner.Bytes(), &o); err != nil {
+				return fmt.Errorf("error in decoding row %d of stock_orders.jsonl %s", row, err)
+			}
+			for _, it := range o.Items {
+				if err := fn("stock_orders.jsonl", row, it); err != nil {
+					return err
+				}
+			}
+		}
+		return scanner.Err()
+	}
+	return fmt.Errorf("unknown order format %s", format)
+}
+
+// readUsers returns the values of a named column of the users export, the
+// column has to hold the oracle login(e.g. CGM_DDB_STEF) as that is what
+// the created_by column of the annotations is matched against
+func readUsers(path, column string) (map[string]bool, error) {
+	users := make(map[string]bool)
+	index := -1
+	err := readCSVRecords(path, false, func(row int, rec []string) error {
+		if index < 0 {
+			for i, name := range rec {
+				if strings.EqualFold(strings.TrimSpace(name), column) {
+					index = i
+				}
+			}
+			if index < 0 {
+				return fmt.Errorf(
+					"users file %s has no %s column, the columns are %s",
+					path, column, strings.Join(rec, ","),
+				)
+			}
+			return nil
+		}
+		if index < len(rec) {
+			users[strings.ToLower(rec[index])] = true
+		}
+		return nil
+	})
+	if err == nil && index < 0 {
+		err = fmt.Errorf("users file %s is empty", path)
+	}
+	return users, err
+}
+
+// VerifyStockCenter checks that the orders and user annotations refer to
+// stocks of the catalog, that the plasmid names of the orders resolve to
+// an id, that the annotations are by known users and that no id is
+// repeated within a file
+func VerifyStockCenter(in VerifyInputs) (*VerifyReport, error) {
+	report := &VerifyReport{
+		Files:      make(map[string]int),
+		Counts:     make(map[string]int),
+		Violations: make([]Violation, 0),
+	}
+	sc, err := ReadStockCatalog(in.StrainCatalog, in.PlasmidCatalog, report)
+	if err != nil {
+		return nil, err
+	}
+	for _, cf := range []struct {
+		name string
+		ids  stockIDs
+	}{
+		{filepath.Base(in.StrainCatalog), sc.Strains},
+		{filepath.Base(in.PlasmidCatalog), sc.Plasmids},
+	} {
+		for _, id := range sortedKeys(cf.ids) {
+			if rows := cf.ids[id]; len(rows) > 1 {
+				report.add(DuplicateID, cf.name, rows[1], id, "id is repeated in %d rows", len(rows))
+			}
+		}
+	}
+	err = orderItems(in.Folder, in.OrdersFormat, func(file string, row int, it OrderItem) error {
+		report.Files[file]++
+		switch it.Kind {
+		case StrainItem:
+			if _, ok := sc.Strains[it.ID]; !ok {
+				report.add(UnknownStock, file, row, it.ID, "strain is not in the catalog")
+			}
+		case PlasmidItem:
+			sc.checkPlasmid(report, file, row, it.ID)
+		default:
+			if _, ok := sc.Strains[it.ID]; ok {
+				return nil
+			}
+			if id, ids := sc.ResolvePlasmid(it.ID); len(id) > 0 || len(ids) > 1 {
+				sc.checkPlasmid(report, file, row, it.ID)
+				return nil
+			}
+			report.add(UnknownStock, file, row, it.ID, "item is neither a strain nor a plasmid of the catalog")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var users map[string]bool
+	if len(in.UserColumn) > 0 {
+		users, err = readUsers(in.UsersFile, in.UserColumn)
+		if err != nil {
+			return nil, err
+		}
+		report.Files[filepath.Base(in.UsersFile)] = len(users)
+	}
+	for _, af := range []struct {
+		name string
+		ids  stockIDs
+	}{
+		{"strain_user_annotations.csv", sc.Strains},
+		{"plasmid_user_annotations.csv", sc.Plasmids},
+	} {
+		seen := make(map[string]bool)
+		err := readCSVRecords(filepath.Join(in.Folder, af.name), false, func(row int, rec []string) error {
+			report.Files[af.name]++
+			id := rec[0]
+			if seen[id] {
+				report.add(DuplicateID, af.name, row, id, "id is repeated")
+			}
+			seen[id] = true
+			if _, ok := af.ids[id]; !ok {
+				report.add(UnknownStock, af.name, row, id, "annotated stock is not in the catalog")
+			}
+			if users != nil && len(rec) > 1 && !users[strings.ToLower(rec[1])] {
+				report.add(UnknownUser, af.name, row, rec[1], "annotation of %s is by a user missing from the colleagues export", id)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+func sortedKeys(ids stockIDs) []string {
+	keys := make([]string, 0, len(ids))
+	for k := range ids {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func DscVerifyAction(c *cli.Context) error {
+	for _, f := range []string{"strain-catalog", "plasmid-catalog"} {
+		if len(c.String(f)) == 0 {
+			return cli.NewExitError(
+				fmt.Sprintf("argument %s is required, the file written by dsc-annotations", f),
+				2,
+			)
+		}
+	}
+	in := VerifyInputs{
+		Folder:         c.String("output-folder"),
+		OrdersFormat:   c.String("orders-format"),
+		StrainCatalog:  c.String("strain-catalog"),
+		PlasmidCatalog: c.String("plasmid-catalog"),
+		UsersFile:      c.String("users-file"),
+		UserColumn:     c.String("user-column"),
+	}
+	report, err := VerifyStockCenter(in)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	out := os.Stdout
+	if len(c.String("output")) > 0 {
+		out, err = os.Create(c.String("output"))
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("error in writing file %s", err), 2)
+		}
+		defer out.Close()
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return cli.NewExitError(fmt.Sprintf("error in writing report %s", err), 2)
+	}
+	log := getLogger(c)
+	if len(in.UserColumn) == 0 {
+		log.Warn("no user column given, the annotations are not checked against the colleagues")
+	}
+	if len(report.Violations) > 0 {
+		log.Errorf("found %d violations in %s", len(report.Violations), in.Folder)
+		return cli.NewExitError("", 1)
+	}
+	log.Infof("no violations found in %s", in.Folder)
+	return nil
+}
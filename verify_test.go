@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeExports(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestVerifyStockCenter(t *testing.T) {
+	exports := map[string]string{
+		"strains.csv": "DBS0235559,AX4\nDBS0236123,pkaC-\nDBS0236123,pkaC-\n",
+		"plasmids.csv": "DBP0000027,pDXA-GFP2\nDBP0000120,pLPBLP\n" +
+			"DBP0000121,pLPBLP\n",
+		"stock_orders.csv": "2011-03-04 10:20:30,jane@example.org,pDXA-GFP2,DBS0235559\n" +
+			"2012-01-02 00:00:00,joe@example.org,pLPBLP,pGone,DBS9999999\n",
+		"strain_user_annotations.csv": "DBS0235559,CGM_DDB_STEF,2008-07-09 10:11:12,2012-03-04 05:06:07\n",
+		"plasmid_user_annotations.csv": "DBP0000027,CGM_DDB_KPIL,2008-07-09 10:11:12,2012-03-04 05:06:07\n" +
+			"DBP0000027,CGM_DDB_KPIL,2008-07-09 10:11:12,2012-03-04 05:06:07\n" +
+			"DBP0000099,CGM_DDB_GONE,2008-07-09 10:11:12,2012-03-04 05:06:07\n",
+		"users.csv": "name,login\nStefan,cgm_ddb_stef\nKaren,cgm_ddb_kpil\n",
+	}
+	dir := writeExports(t, exports)
+	inputs := func(dir, format string) VerifyInputs {
+		return VerifyInputs{
+			Folder:         dir,
+			OrdersFormat:   format,
+			StrainCatalog:  filepath.Join(dir, "strains.csv"),
+			PlasmidCatalog: filepath.Join(dir, "plasmids.csv"),
+		}
+	}
+	in := inputs(dir, "legacy")
+	in.UsersFile, in.UserColumn = filepath.Join(dir, "users.csv"), "login"
+	report, err := VerifyStockCenter(in)
+	require.NoError(t, err)
+	assert.Equal(t, []Violation{
+		{DuplicateID, "strains.csv", 3, "DBS0236123", "id is repeated in 2 rows"},
+		{UnresolvedPlasmid, "stock_orders.csv", 2, "pLPBLP", "plasmid name is shared by DBP0000120,DBP0000121"},
+		{UnknownStock, "stock_orders.csv", 2, "pGone", "item is neither a strain nor a plasmid of the catalog"},
+		{UnknownStock, "stock_orders.csv", 2, "DBS9999999", "item is neither a strain nor a plasmid of the catalog"},
+		{DuplicateID, "plasmid_user_annotations.csv", 2, "DBP0000027", "id is repeated"},
+		{UnknownStock, "plasmid_user_annotations.csv", 3, "DBP0000099", "annotated stock is not in the catalog"},
+		{UnknownUser, "plasmid_user_annotations.csv", 3, "CGM_DDB_GONE", "annotation of DBP0000099 is by a user missing from the colleagues export"},
+	}, report.Violations)
+	assert.Equal(t, map[string]int{UnknownStock: 3, UnresolvedPlasmid: 1, UnknownUser: 1, DuplicateID: 2}, report.Counts)
+	assert.Equal(t, 5, report.Files["stock_orders.csv"])
+
+	t.Run("normalized orders", func(t *testing.T) {
+		exports["order_items.csv"] = "order_id,kind,item\n11,plasmid,DBP0000027\n11,plasmid,pGone\n12,strain,DBS0235559\n"
+		exports["strains.csv"] = "DBS0235559,AX4\n"
+		exports["plasmid_user_annotations.csv"] = "DBP0000027,CGM_DDB_KPIL,2008-07-09 10:11:12,2012-03-04 05:06:07\n"
+		report, err := VerifyStockCenter(inputs(writeExports(t, exports), "normalized"))
+		require.NoError(t, err)
+		assert.Equal(t, []Violation{
+			{UnresolvedPlasmid, "order_items.csv", 2, "pGone", "plasmid name has no id in the catalog"},
+		}, report.Violations)
+	})
+	t.Run("missing catalog", func(t *testing.T) {
+		_, err := VerifyStockCenter(inputs(t.TempDir(), "legacy"))
+		assert.Error(t, err)
+	})
+	t.Run("plasmid without a name", func(t *testing.T) {
+		exports["plasmids.csv"] = "DBP0000027\n"
+		_, err := VerifyStockCenter(inputs(writeExports(t, exports), "legacy"))
+		assert.EqualError(t, err, "row 1 of plasmids.csv has no plasmid name after the id")
+	})
+	t.Run("missing user column", func(t *testing.T) {
+		in := inputs(dir, "legacy")
+		in.UsersFile, in.UserColumn = filepath.Join(dir, "users.csv"), "created_by"
+		_, err := VerifyStockCenter(in)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "has no created_by column, the columns are name,login")
+	})
+}